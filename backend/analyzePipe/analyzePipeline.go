@@ -3,19 +3,99 @@ package analyzePipe
 import (
 	"awesomeProject1/backend/model"
 	"awesomeProject1/backend/utils"
-	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"context"
+	"fmt"
 	"log"
-	"time"
 )
 
-func AnalyzePipeline() bool {
-	analyzer := model.NewAnalyzer(utils.LogDB)
-	analyzer.RunAnalysis()
+// 分析阶段
+const (
+	StageDetecting   = "detecting"   // 规则检测
+	StageCorrelating = "correlating" // 时序关联
+	StageGraphing    = "graphing"    // 攻击图构建
+)
+
+// 分析所需的存储，由调用方注入
+type Stores struct {
+	Flows  model.FlowStore
+	Events model.EventStore
+	Graph  model.GraphStore
+}
+
+// 单次分析运行参数
+type PipelineOptions struct {
+	CaseID            uint               // 分析的案件，0为默认案件
+	Window            model.TimeWindow   // 分析时间窗口，未指定的边界取数据集的时间范围
+	Incremental       bool               // 仅检测受上次成功分析后入库的日志影响的攻击
+	EnabledDetectors  []string           // 本次运行额外启用的规则
+	DisabledDetectors []string           // 本次运行禁用的规则
+	OnStage           func(stage string) // 阶段切换回调
+}
+
+func (opts PipelineOptions) enter(stage string) {
+	if opts.OnStage != nil {
+		opts.OnStage(stage)
+	}
+}
+
+func AnalyzePipeline(ctx context.Context, stores Stores, opts PipelineOptions) error {
+	if opts.CaseID == 0 {
+		opts.CaseID = utils.DefaultCaseID
+	}
+	analyzer := model.NewAnalyzer(stores.Flows, stores.Events, opts.CaseID)
+	for _, name := range opts.EnabledDetectors {
+		if err := analyzer.SetDetectorEnabled(name, true); err != nil {
+			return fmt.Errorf("规则配置失败: %v", err)
+		}
+	}
+	for _, name := range opts.DisabledDetectors {
+		if err := analyzer.SetDetectorEnabled(name, false); err != nil {
+			return fmt.Errorf("规则配置失败: %v", err)
+		}
+	}
+
+	window, err := resolveWindow(stores.Flows, opts.CaseID, opts.Window)
+	if err != nil {
+		return err
+	}
+	if window.Start.IsZero() || window.End.IsZero() {
+		log.Printf("案件无日志数据，跳过分析 (案件:%d)", opts.CaseID)
+		return nil
+	}
+	log.Printf("分析时间窗口: %s (案件:%d)", window, opts.CaseID)
+
+	// 分析开始时的入库水位，分析期间新入库的日志留待下次处理
+	mark, err := stores.Flows.IngestWatermark(opts.CaseID)
+	if err != nil {
+		return err
+	}
+	detectWindow, detect := window, true
+	if opts.Incremental {
+		if detectWindow, detect, err = incrementalWindow(stores, opts.CaseID, window, mark); err != nil {
+			return err
+		}
+		if !detect {
+			log.Printf("无新入库数据，跳过分析 (案件:%d)", opts.CaseID)
+			return nil
+		}
+	}
+
+	opts.enter(StageDetecting)
+	if err := analyzer.RunAnalysis(ctx, detectWindow); err != nil {
+		return err
+	}
 
-	correlator := model.NewTemporalCorrelator(utils.LogDB)
-	phases, _ := correlator.DetectPhaseTransitions(time.Now().Add(-24*time.Hour), time.Now())
+	opts.enter(StageCorrelating)
+	correlator := model.NewTemporalCorrelator(stores.Events, opts.CaseID)
+	phases, _ := correlator.DetectPhaseTransitions(window.Start, window.End)
+	chains, _ := correlator.PivotChains(window.Start, window.End)
 
-	builder := model.NewAttackGraphBuilder()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	opts.enter(StageGraphing)
+	builder := model.NewAttackGraphBuilder(stores.Graph, opts.CaseID)
 	inferer := model.NewBayesianInferer()
 
 	// 构建攻击图
@@ -30,45 +110,48 @@ func AnalyzePipeline() bool {
 		builder.AddPhaseTransition(prev, current)
 	}
 
+	// 跳板链写入主机节点
+	for _, chain := range chains {
+		builder.AddPivotChain(chain)
+	}
+
 	// 生成攻击路径
 	inferer.GeneratePaths(phases)
 
-	if err := builder.SaveToNeo4j(); err != nil {
-		log.Printf("攻击图存储失败: %v", err)
-		return false
-	}
-
-	// 保存攻击路径
 	log.Printf("准备存储攻击图 (节点:%d 边:%d)", len(builder.Nodes), len(builder.Edges))
-	if err := builder.SaveToNeo4j(); err != nil {
+	if err := builder.Save(); err != nil {
 		log.Printf("攻击图存储失败: %v", err)
-		return false
+		return fmt.Errorf("攻击图存储失败: %v", err)
 	}
-	return true
-}
 
-func savePathsToNeo4j(paths []model.AttackPath) error {
-	session := utils.Neo4jDriver.NewSession(neo4j.SessionConfig{})
-	defer session.Close()
-
-	for _, path := range paths {
-		_, err := session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
-			// 创建路径节点
-			_, err := tx.Run(
-				`CREATE (p:AttackPath {confidence: $conf})
-				WITH p
-				UNWIND $phases AS phase
-				MATCH (n:AttackPhase {phase: phase})
-				MERGE (p)-[:CONTAINS]->(n)`,
-				map[string]interface{}{
-					"conf":   path.Confidence,
-					"phases": path.Phases,
-				})
-			return nil, err
-		})
-		if err != nil {
+	// 仅覆盖全部数据的分析推进水位，指定窗口的分析不代表其余数据已处理
+	if opts.Window.IsZero() {
+		if err := stores.Events.SaveWatermark(mark); err != nil {
 			return err
 		}
 	}
 	return nil
 }
+
+// 增量分析的检测窗口：上次成功分析后入库的日志所影响的攻击时间范围与window的交集
+func incrementalWindow(stores Stores, caseID uint, window model.TimeWindow, mark utils.AnalysisWatermark) (model.TimeWindow, bool, error) {
+	last, err := stores.Events.Watermark(caseID)
+	if err != nil {
+		return model.TimeWindow{}, false, err
+	}
+	if last.AttackLogID >= mark.AttackLogID && last.TcpLogID >= mark.TcpLogID {
+		return model.TimeWindow{}, false, nil
+	}
+	changed, err := stores.Flows.IngestedRange(caseID, last)
+	if err != nil {
+		return model.TimeWindow{}, false, err
+	}
+	if changed.IsZero() {
+		return model.TimeWindow{}, false, nil
+	}
+	affected, ok := window.Intersect(model.CurrentThresholds().AffectedWindow(changed))
+	if ok {
+		log.Printf("增量分析: 新入库日志 %s，重新检测 %s (案件:%d)", changed, affected, caseID)
+	}
+	return affected, ok, nil
+}
@@ -0,0 +1,258 @@
+package analyzePipe
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"awesomeProject1/backend/model"
+	"awesomeProject1/backend/store"
+	"awesomeProject1/backend/utils"
+)
+
+const (
+	testCase     = 1
+	testAttacker = "198.51.100.5"
+	testVictim   = "10.0.0.20"
+	testC2       = "203.0.113.7"
+	testHidden   = "10.0.0.30"
+)
+
+var attackTime = time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+
+func testFlow(client, server string, port int, start time.Time) utils.TcpLog {
+	return utils.TcpLog{
+		CaseID: testCase, ClientIP: client, ServerIP: server, ServerPort: port,
+		StartTime: start, EndTime: start.Add(5 * time.Second), UpBytes: 800, DownBytes: 1200,
+	}
+}
+
+// 攻击者攻击受害者后，受害者周期性回连C2；攻击者另访问攻击日志未记录的主机
+func newTestStore() *store.MemoryStore {
+	s := store.NewMemoryStore()
+	s.AddAttackLogs(utils.AttackLog{
+		CaseID: testCase, LogTime: attackTime, EventType: 1, SourceIP: testAttacker, DestIP: testVictim, Severity: 3,
+	})
+	s.AddFlows(testFlow(testAttacker, testVictim, 80, attackTime.Add(time.Minute)))
+	for i := 0; i < 10; i++ {
+		s.AddFlows(testFlow(testVictim, testC2, 443, attackTime.Add(10*time.Minute+time.Duration(i)*time.Minute)))
+	}
+	s.AddFlows(
+		testFlow(testAttacker, testHidden, 22, attackTime.Add(3*time.Hour+10*time.Minute)),
+		testFlow(testAttacker, testHidden, 22, attackTime.Add(3*time.Hour+20*time.Minute)),
+		testFlow(testAttacker, testHidden, 3389, attackTime.Add(3*time.Hour+30*time.Minute)),
+	)
+	return s
+}
+
+func runPipeline(t *testing.T, s *store.MemoryStore, opts PipelineOptions) {
+	t.Helper()
+	opts.CaseID = testCase
+	if err := AnalyzePipeline(context.Background(), Stores{Flows: s, Events: s, Graph: s}, opts); err != nil {
+		t.Fatalf("AnalyzePipeline() error = %v", err)
+	}
+}
+
+func eventsBy(s *store.MemoryStore, detector string) []utils.APTEvent {
+	var result []utils.APTEvent
+	for _, e := range s.Events(testCase) {
+		if e.Detector == detector {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+func TestAnalyzePipelineCancelled(t *testing.T) {
+	s := newTestStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := AnalyzePipeline(ctx, Stores{Flows: s, Events: s, Graph: s}, PipelineOptions{CaseID: testCase})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("AnalyzePipeline() error = %v, want context.Canceled", err)
+	}
+	if n := len(s.Events(testCase)); n != 0 {
+		t.Errorf("取消后事件数 = %d, want 0", n)
+	}
+	if w, _ := s.Watermark(testCase); w.TcpLogID != 0 {
+		t.Errorf("取消后分析水位 = %+v, want 未推进", w)
+	}
+}
+
+func TestAnalyzePipelineWatermark(t *testing.T) {
+	s := newTestStore()
+	watermark := func() utils.AnalysisWatermark {
+		t.Helper()
+		w, err := s.Watermark(testCase)
+		if err != nil {
+			t.Fatalf("Watermark() error = %v", err)
+		}
+		return w
+	}
+
+	// 指定窗口的分析不推进水位
+	runPipeline(t, s, PipelineOptions{Window: model.TimeWindow{Start: attackTime, End: attackTime.Add(time.Hour)}})
+	if w := watermark(); w.TcpLogID != 0 || w.AttackLogID != 0 {
+		t.Errorf("指定窗口分析后水位 = %+v, want 未推进", w)
+	}
+
+	runPipeline(t, s, PipelineOptions{})
+	if w := watermark(); w.TcpLogID != 14 || w.AttackLogID != 1 {
+		t.Errorf("全量分析后水位 = %+v, want TcpLogID=14 AttackLogID=1", w)
+	}
+	events := len(s.Events(testCase))
+
+	// 无新入库数据时增量分析跳过
+	runPipeline(t, s, PipelineOptions{Incremental: true})
+	if got := len(s.Events(testCase)); got != events {
+		t.Errorf("无新数据增量分析后事件数 = %d, want %d", got, events)
+	}
+
+	s.AddFlows(testFlow(testVictim, testC2, 443, attackTime.Add(20*time.Minute)))
+	runPipeline(t, s, PipelineOptions{Incremental: true})
+	if w := watermark(); w.TcpLogID != 15 {
+		t.Errorf("增量分析后水位 = %+v, want TcpLogID=15", w)
+	}
+}
+
+func TestAnalyzePipelineIsIdempotent(t *testing.T) {
+	s := newTestStore()
+	runPipeline(t, s, PipelineOptions{})
+	first := s.Events(testCase)
+
+	runPipeline(t, s, PipelineOptions{})
+	if got := s.Events(testCase); len(got) != len(first) {
+		t.Errorf("重复分析后事件数 = %d, want %d", len(got), len(first))
+	}
+}
+
+func TestAnalyzePipelineDetectsBeacon(t *testing.T) {
+	s := newTestStore()
+	runPipeline(t, s, PipelineOptions{})
+
+	beacons := eventsBy(s, "beaconing")
+	if len(beacons) != 1 {
+		t.Fatalf("信标事件数 = %d, want 1", len(beacons))
+	}
+	b := beacons[0]
+	if b.SourceIP != testVictim || b.EventName != "C2Communication" {
+		t.Errorf("信标事件 = %+v", b)
+	}
+	var metadata struct {
+		Beacons []struct {
+			ServerIP string  `json:"serverIp"`
+			Period   float64 `json:"periodSeconds"`
+		} `json:"beacons"`
+	}
+	if err := json.Unmarshal(b.Metadata, &metadata); err != nil || len(metadata.Beacons) != 1 {
+		t.Fatalf("信标结构化信息 = %s (%v)", b.Metadata, err)
+	}
+	if got := metadata.Beacons[0]; got.ServerIP != testC2 || got.Period != 60 {
+		t.Errorf("信标 = %+v, want %s 周期60秒", got, testC2)
+	}
+}
+
+func TestAnalyzePipelineHiddenAttacks(t *testing.T) {
+	s := newTestStore()
+	runPipeline(t, s, PipelineOptions{})
+
+	hidden := eventsBy(s, "hidden_attack")
+	if len(hidden) != 2 {
+		t.Fatalf("隐藏攻击事件数 = %d, want 2（每个目标服务一个）: %+v", len(hidden), hidden)
+	}
+	byPort := make(map[int]utils.APTEvent)
+	for _, e := range hidden {
+		if e.SourceIP != testAttacker || e.DestIP != testHidden {
+			t.Errorf("隐藏攻击事件 = %+v，攻击日志已记录的会话不应生成事件", e)
+		}
+		byPort[e.DestPort] = e
+	}
+	ssh, ok := byPort[22]
+	if !ok {
+		t.Fatalf("缺少22端口的隐藏攻击事件: %+v", hidden)
+	}
+	// 事件时间取命中会话本身的时间
+	if want := attackTime.Add(3*time.Hour + 10*time.Minute); !ssh.StartTime.Equal(want) {
+		t.Errorf("StartTime = %v, want %v", ssh.StartTime, want)
+	}
+	if want := attackTime.Add(3*time.Hour + 20*time.Minute + 5*time.Second); !ssh.EndTime.Equal(want) {
+		t.Errorf("EndTime = %v, want %v", ssh.EndTime, want)
+	}
+	if _, ok := byPort[3389]; !ok {
+		t.Errorf("缺少3389端口的隐藏攻击事件: %+v", hidden)
+	}
+
+	// 禁用规则后不再生成
+	s = newTestStore()
+	runPipeline(t, s, PipelineOptions{DisabledDetectors: []string{"hidden_attack"}})
+	if n := len(eventsBy(s, "hidden_attack")); n != 0 {
+		t.Errorf("禁用后隐藏攻击事件数 = %d, want 0", n)
+	}
+}
+
+func TestAnalyzePipelineIncrementalMergesHiddenAttacks(t *testing.T) {
+	s := newTestStore()
+	runPipeline(t, s, PipelineOptions{})
+	before := eventsBy(s, "hidden_attack")
+
+	// 同一时段新增的会话合并到已有事件，事件开始时间不随增量窗口变化
+	s.AddFlows(testFlow(testAttacker, testHidden, 22, attackTime.Add(3*time.Hour+40*time.Minute)))
+	runPipeline(t, s, PipelineOptions{Incremental: true})
+
+	after := eventsBy(s, "hidden_attack")
+	if len(after) != len(before) {
+		t.Fatalf("增量分析后隐藏攻击事件数 = %d, want %d", len(after), len(before))
+	}
+	for _, e := range after {
+		if e.DestPort != 22 {
+			continue
+		}
+		if want := attackTime.Add(3*time.Hour + 10*time.Minute); !e.StartTime.Equal(want) {
+			t.Errorf("StartTime = %v, want %v", e.StartTime, want)
+		}
+		if want := attackTime.Add(3*time.Hour + 40*time.Minute + 5*time.Second); !e.EndTime.Equal(want) {
+			t.Errorf("EndTime = %v, want %v", e.EndTime, want)
+		}
+	}
+
+	// 窗口内其他时段的会话仍归入同一目标服务的事件，不按小时拆分
+	s.AddFlows(testFlow(testAttacker, testHidden, 22, attackTime.Add(5*time.Hour)))
+	runPipeline(t, s, PipelineOptions{Incremental: true})
+	after = eventsBy(s, "hidden_attack")
+	if len(after) != len(before) {
+		t.Fatalf("跨时段会话后隐藏攻击事件数 = %d, want %d", len(after), len(before))
+	}
+	for _, e := range after {
+		if e.DestPort != 22 {
+			continue
+		}
+		if want := attackTime.Add(5*time.Hour + 5*time.Second); !e.EndTime.Equal(want) {
+			t.Errorf("EndTime = %v, want %v", e.EndTime, want)
+		}
+	}
+}
+
+// 记录攻击图写入次数
+type countingGraph struct {
+	*store.MemoryStore
+	replaced int
+}
+
+func (g *countingGraph) ReplaceGraph(caseID uint, nodes []model.AttackNode, edges []model.AttackEdge) error {
+	g.replaced++
+	return g.MemoryStore.ReplaceGraph(caseID, nodes, edges)
+}
+
+func TestAnalyzePipelineSavesGraphOnce(t *testing.T) {
+	s := newTestStore()
+	graph := &countingGraph{MemoryStore: s}
+	if err := AnalyzePipeline(context.Background(), Stores{Flows: s, Events: s, Graph: graph}, PipelineOptions{CaseID: testCase}); err != nil {
+		t.Fatalf("AnalyzePipeline() error = %v", err)
+	}
+	if graph.replaced != 1 {
+		t.Errorf("攻击图写入次数 = %d, want 1", graph.replaced)
+	}
+}
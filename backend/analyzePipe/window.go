@@ -0,0 +1,72 @@
+package analyzePipe
+
+import (
+	"awesomeProject1/backend/model"
+	"fmt"
+	"time"
+)
+
+// 时间窗口参数支持的格式，与日志时间一致按UTC解析
+var windowLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+const dateLayout = "2006-01-02"
+
+func parseWindowTime(value string) (time.Time, string, error) {
+	for _, layout := range windowLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, layout, nil
+		}
+	}
+	return time.Time{}, "", fmt.Errorf("时间格式错误: %q", value)
+}
+
+// 解析分析时间窗口，空串表示使用数据集对应的边界；
+// 仅给出日期的结束时间包含当天全天
+func ParseWindow(start, end string) (model.TimeWindow, error) {
+	var window model.TimeWindow
+	var err error
+	if start != "" {
+		if window.Start, _, err = parseWindowTime(start); err != nil {
+			return model.TimeWindow{}, err
+		}
+	}
+	if end != "" {
+		var layout string
+		if window.End, layout, err = parseWindowTime(end); err != nil {
+			return model.TimeWindow{}, err
+		}
+		if layout == dateLayout {
+			window.End = window.End.Add(24*time.Hour - time.Nanosecond)
+		}
+	}
+	if !window.Start.IsZero() && !window.End.IsZero() && window.End.Before(window.Start) {
+		return model.TimeWindow{}, fmt.Errorf("时间窗口无效: 结束时间早于开始时间")
+	}
+	return window, nil
+}
+
+// 补全未指定的窗口边界为案件数据集的实际时间范围
+func resolveWindow(flows model.FlowStore, caseID uint, window model.TimeWindow) (model.TimeWindow, error) {
+	if !window.Start.IsZero() && !window.End.IsZero() {
+		return window, nil
+	}
+	dataRange, err := flows.DataRange(caseID)
+	if err != nil {
+		return model.TimeWindow{}, err
+	}
+	if window.Start.IsZero() {
+		window.Start = dataRange.Start
+	}
+	if window.End.IsZero() {
+		window.End = dataRange.End
+	}
+	if !window.Start.IsZero() && !window.End.IsZero() && window.End.Before(window.Start) {
+		return model.TimeWindow{}, fmt.Errorf("时间窗口无效: %s", window)
+	}
+	return window, nil
+}
@@ -0,0 +1,82 @@
+package bootstrap
+
+import (
+	"awesomeProject1/backend/analyzePipe"
+	"awesomeProject1/backend/config"
+	"awesomeProject1/backend/handler"
+	"awesomeProject1/backend/migrate"
+	"awesomeProject1/backend/model"
+	"awesomeProject1/backend/store"
+	"awesomeProject1/backend/utils"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// 服务与命令行工具共用的启动流程
+
+// 加载检测阈值、规则文件、TCP列映射方案与入库参数
+func LoadDetection(cfg *config.Config) error {
+	if err := model.LoadThresholds(cfg.Files.Thresholds); err != nil {
+		return err
+	}
+	if err := model.LoadRuleFile(cfg.Files.Rules); err != nil {
+		return err
+	}
+	if err := handler.LoadTcpProfiles(cfg.Files.TcpProfiles); err != nil {
+		return err
+	}
+	handler.IngestBatchSize = cfg.Ingest.BatchSize
+	handler.UploadDir = cfg.Server.UploadDir
+	return nil
+}
+
+// 收到SIGHUP时重新加载检测阈值与规则文件
+func WatchConfigReload() {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	go func() {
+		for range sighup {
+			if err := model.ReloadThresholds(); err != nil {
+				log.Printf("阈值配置重载失败，保留原配置: %v", err)
+			}
+			if err := model.ReloadRuleFile(); err != nil {
+				log.Printf("规则文件重载失败，保留原规则: %v", err)
+			}
+		}
+	}()
+}
+
+// 连接数据库（不执行迁移）
+func OpenDatabase(cfg *config.Config) error {
+	return utils.InitDatabase(cfg.Database)
+}
+
+// 连接数据库、迁移数据表、初始化默认案件与分析存储
+func OpenStores(cfg *config.Config) (analyzePipe.Stores, error) {
+	if err := OpenDatabase(cfg); err != nil {
+		return analyzePipe.Stores{}, err
+	}
+	if n, err := migrate.Up(utils.LogDB, 0); err != nil {
+		return analyzePipe.Stores{}, err
+	} else if n > 0 {
+		log.Printf("数据表迁移完成 (执行%d个迁移)", n)
+	}
+	if err := utils.EnsureDefaultCase(utils.LogDB); err != nil {
+		return analyzePipe.Stores{}, err
+	}
+	graphStore, err := store.OpenGraphStore(cfg.Graph, utils.LogDB)
+	if err != nil {
+		return analyzePipe.Stores{}, err
+	}
+
+	sqlStore := store.NewGormStore(utils.LogDB)
+	stores := analyzePipe.Stores{
+		Flows:  sqlStore,
+		Events: sqlStore,
+		Graph:  graphStore,
+	}
+	handler.AnalysisStores = stores
+	return stores, nil
+}
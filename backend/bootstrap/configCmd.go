@@ -0,0 +1,26 @@
+package bootstrap
+
+import (
+	"awesomeProject1/backend/config"
+	"fmt"
+	"os"
+)
+
+const configUsage = `用法: config <命令>
+  print           输出生效的配置（敏感信息打码）
+  check           仅校验配置`
+
+// 配置子命令，配置已在加载时完成校验
+func RunConfig(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%s", configUsage)
+	}
+	switch args[0] {
+	case "print":
+		return cfg.Print(os.Stdout)
+	case "check":
+		fmt.Println("配置有效")
+		return nil
+	}
+	return fmt.Errorf("未知命令: %s\n%s", args[0], configUsage)
+}
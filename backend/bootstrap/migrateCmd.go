@@ -0,0 +1,70 @@
+package bootstrap
+
+import (
+	"awesomeProject1/backend/migrate"
+	"awesomeProject1/backend/utils"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+const migrateUsage = `用法: migrate <命令>
+  status          查看迁移状态
+  up [版本]       执行迁移至指定版本（默认最新）
+  down [数量]     回滚最近的迁移（默认1个）`
+
+// 数据表迁移子命令，args为migrate之后的参数
+func RunMigrate(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%s", migrateUsage)
+	}
+
+	intArg := func(def int) (int, error) {
+		if len(args) < 2 {
+			return def, nil
+		}
+		v, err := strconv.Atoi(args[1])
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("无效参数: %s", args[1])
+		}
+		return v, nil
+	}
+
+	switch args[0] {
+	case "status":
+		statuses, err := migrate.Status(utils.LogDB)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "版本\t名称\t状态\t执行时间")
+		for _, s := range statuses {
+			state, at := "未执行", ""
+			if s.Applied {
+				state, at = "已执行", s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%03d\t%s\t%s\t%s\n", s.Version, s.Name, state, at)
+		}
+		return w.Flush()
+
+	case "up":
+		target, err := intArg(0)
+		if err != nil {
+			return err
+		}
+		n, err := migrate.Up(utils.LogDB, target)
+		fmt.Printf("执行%d个迁移\n", n)
+		return err
+
+	case "down":
+		steps, err := intArg(1)
+		if err != nil {
+			return err
+		}
+		n, err := migrate.Down(utils.LogDB, steps)
+		fmt.Printf("回滚%d个迁移\n", n)
+		return err
+	}
+	return fmt.Errorf("未知命令: %s\n%s", args[0], migrateUsage)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"awesomeProject1/backend/analyzePipe"
+	"context"
+	"log"
+	"strings"
+	"time"
+)
+
+// 对案件运行完整分析流程
+func runAnalyze(ctx context.Context, args []string) error {
+	fs := newFlagSet("analyze", "[参数]")
+	enable := fs.String("enable", "", "本次额外启用的规则，逗号分隔")
+	disable := fs.String("disable", "", "本次禁用的规则，逗号分隔")
+	start := fs.String("start", "", "分析窗口开始时间，默认为数据集最早时间")
+	end := fs.String("end", "", "分析窗口结束时间，默认为数据集最晚时间")
+	incremental := fs.Bool("incremental", false, "仅分析上次成功分析后入库的数据")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	window, err := analyzePipe.ParseWindow(*start, *end)
+	if err != nil {
+		return err
+	}
+
+	stores, err := fs.openStores()
+	if err != nil {
+		return err
+	}
+	caseID, err := fs.resolveCase()
+	if err != nil {
+		return err
+	}
+
+	return analyzeCase(ctx, stores, analyzePipe.PipelineOptions{
+		CaseID:            caseID,
+		Window:            window,
+		Incremental:       *incremental,
+		EnabledDetectors:  splitList(*enable),
+		DisabledDetectors: splitList(*disable),
+	})
+}
+
+func analyzeCase(ctx context.Context, stores analyzePipe.Stores, opts analyzePipe.PipelineOptions) error {
+	begin := time.Now()
+	opts.OnStage = func(stage string) {
+		log.Printf("分析阶段: %s (案件:%d)", stage, opts.CaseID)
+	}
+	if err := analyzePipe.AnalyzePipeline(ctx, stores, opts); err != nil {
+		return err
+	}
+	log.Printf("分析完成 (案件:%d 耗时:%s)", opts.CaseID, time.Since(begin).Round(time.Millisecond))
+	return nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"awesomeProject1/backend/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+const eventsUsage = `用法: aptrace events list [参数]`
+
+func runEvents(_ context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "list" {
+		return fmt.Errorf("%s", eventsUsage)
+	}
+	fs := newFlagSet("events list", "[参数]")
+	eventType := fs.String("type", "", "仅列出指定类型的事件")
+	limit := fs.Int("limit", 50, "最多列出的事件数，0为不限")
+	offset := fs.Int("offset", 0, "跳过的事件数")
+	asJSON := fs.Bool("json", false, "以JSON输出")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if _, err := fs.openStores(); err != nil {
+		return err
+	}
+	caseID, err := fs.resolveCase()
+	if err != nil {
+		return err
+	}
+
+	query := utils.LogDB.Where("case_id = ?", caseID)
+	if *eventType != "" {
+		query = query.Where("event_type = ?", *eventType)
+	}
+	if *limit > 0 {
+		query = query.Limit(*limit).Offset(*offset)
+	} else if *offset > 0 {
+		return fmt.Errorf("-offset需与-limit同时使用")
+	}
+	var events []utils.APTEvent
+	if err := query.Order("start_time asc, id asc").Find(&events).Error; err != nil {
+		return fmt.Errorf("事件查询失败: %v", err)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(events)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\t开始时间\t类型\t源IP\t目标IP\t等级\t次数\t描述")
+	for _, e := range events {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%d\t%d\t%s\n",
+			e.ID, e.StartTime.Format("2006-01-02 15:04:05"), e.EventType, e.SourceIP, e.DestIP, e.SeverityLevel, e.Occurrences, e.Description)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"awesomeProject1/backend/model"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+const graphUsage = `用法: aptrace graph export [参数]`
+
+func runGraph(_ context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "export" {
+		return fmt.Errorf("%s", graphUsage)
+	}
+	fs := newFlagSet("graph export", "[参数]")
+	format := fs.String("format", "json", "导出格式 json|dot")
+	output := fs.String("o", "", "输出文件，默认标准输出")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *format != "json" && *format != "dot" {
+		return fmt.Errorf("不支持的导出格式: %s", *format)
+	}
+
+	stores, err := fs.openStores()
+	if err != nil {
+		return err
+	}
+	caseID, err := fs.resolveCase()
+	if err != nil {
+		return err
+	}
+	nodes, edges, err := stores.Graph.LoadGraph(caseID)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("文件创建失败: %v", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	if *format == "dot" {
+		return writeDot(w, caseID, edges)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(map[string]interface{}{
+		"caseId": caseID,
+		"nodes":  nodes,
+		"edges":  edges,
+	})
+}
+
+// 以Graphviz格式输出阶段转移，跳板关系以虚线表示
+func writeDot(w io.Writer, caseID uint, edges []model.AttackEdge) error {
+	if _, err := fmt.Fprintf(w, "digraph attack_case_%d {\n  rankdir=LR;\n", caseID); err != nil {
+		return err
+	}
+	for _, e := range edges {
+		style := ""
+		if e.Kind == model.EdgePivot {
+			style = ", style=dashed"
+		}
+		if _, err := fmt.Fprintf(w, "  %q -> %q [label=\"%.2f (%d)\"%s];\n", e.From, e.To, e.Confidence, e.Count, style); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
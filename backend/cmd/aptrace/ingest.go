@@ -0,0 +1,108 @@
+package main
+
+import (
+	"awesomeProject1/backend/analyzePipe"
+	"awesomeProject1/backend/handler"
+	"awesomeProject1/backend/migrate"
+	"awesomeProject1/backend/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// 导入日志文件，可选导入后立即分析
+func runIngest(ctx context.Context, args []string) error {
+	fs := newFlagSet("ingest", "[参数] 文件...")
+	logType := fs.String("type", "", "日志类型 attack|tcp，为空时按内容识别")
+	profile := fs.String("profile", "", "TCP日志列映射方案")
+	replace := fs.Bool("replace", false, "重新导入已导入过的文件")
+	analyze := fs.Bool("analyze", false, "导入完成后增量分析新数据")
+	asJSON := fs.Bool("json", false, "以JSON输出导入统计")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("缺少日志文件")
+	}
+	switch *logType {
+	case "", "attack", "tcp":
+	default:
+		return fmt.Errorf("不支持的日志类型: %s", *logType)
+	}
+
+	stores, err := fs.openStores()
+	if err != nil {
+		return err
+	}
+	caseID, err := fs.resolveCase()
+	if err != nil {
+		return err
+	}
+	if _, err := handler.GetTcpProfile(*profile); err != nil {
+		return err
+	}
+
+	opts := handler.IngestOptions{
+		UploadID:   "cli-" + time.Now().Format("20060102150405"),
+		CaseID:     caseID,
+		TcpProfile: *profile,
+		Replace:    *replace,
+	}
+	var summaries []handler.IngestSummary
+	var failed []error
+	for _, path := range fs.Args() {
+		log.Printf("正在处理文件: %s", path)
+		result, err := handler.IngestPath(ctx, path, *logType, opts)
+		summaries = append(summaries, result...)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			failed = append(failed, fmt.Errorf("%s: %v", path, err))
+		}
+	}
+
+	// 新导入的会话可能超出现有分区范围
+	if err := migrate.ExtendTcpPartitions(utils.LogDB); err != nil {
+		log.Printf("%v", err)
+	}
+
+	if err := printSummaries(summaries, *asJSON); err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		for _, err := range failed {
+			log.Printf("%v", err)
+		}
+		return fmt.Errorf("%d个文件导入失败", len(failed))
+	}
+
+	if *analyze {
+		return analyzeCase(ctx, stores, analyzePipe.PipelineOptions{CaseID: caseID, Incremental: true})
+	}
+	return nil
+}
+
+func printSummaries(summaries []handler.IngestSummary, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(summaries)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "文件\t类型\t行数\t入库\t拒绝\t重复\t失败\t质量\t耗时")
+	for _, s := range summaries {
+		if s.Skipped {
+			fmt.Fprintf(w, "%s\t%s\t-\t-\t-\t-\t-\t-\t已导入，跳过\n", s.File, s.Type)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%d\t%.2f\t%s\n",
+			s.File, s.Type, s.Lines, s.Accepted, s.Rejected, s.Duplicates, s.Failed, s.Quality, s.Elapsed)
+	}
+	return w.Flush()
+}
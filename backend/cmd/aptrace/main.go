@@ -0,0 +1,156 @@
+// aptrace 命令行工具：不经HTTP直接导入日志、运行分析、导出攻击图与查询事件，
+// 便于在定时任务与脚本中批量处理调查案件
+package main
+
+import (
+	"awesomeProject1/backend/analyzePipe"
+	"awesomeProject1/backend/bootstrap"
+	"awesomeProject1/backend/config"
+	"awesomeProject1/backend/utils"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+const usage = `用法: aptrace <命令> [参数]
+
+命令:
+  ingest [参数] 文件...     导入攻击日志、TCP会话日志或压缩包
+  analyze [参数]            运行规则检测、时序关联与攻击图构建
+  graph export [参数]       导出案件攻击图（json或dot）
+  events list [参数]        列出案件的APT事件
+  migrate <命令>            管理数据表迁移
+  config <print|check>      输出或校验生效的配置
+
+使用 "aptrace <命令> -h" 查看命令参数，各命令均支持-config等配置参数`
+
+// 子命令：args为命令名之后的参数
+type command func(ctx context.Context, args []string) error
+
+var commands = map[string]command{
+	"ingest":  runIngest,
+	"analyze": runAnalyze,
+	"graph":   runGraph,
+	"events":  runEvents,
+	"migrate": runMigrate,
+	"config":  runConfig,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	name := os.Args[1]
+	if name == "-h" || name == "--help" || name == "help" {
+		fmt.Println(usage)
+		return
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "未知命令: %s\n%s\n", name, usage)
+		os.Exit(2)
+	}
+
+	// 中断时取消正在进行的导入与分析，已写入的批次保留
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := cmd(ctx, os.Args[2:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		log.Printf("%s失败: %v", name, err)
+		stop()
+		os.Exit(1)
+	}
+}
+
+// 子命令参数集，包含各命令共用的案件与配置参数
+type cmdFlags struct {
+	*flag.FlagSet
+	caseID *string
+	config *config.Loader
+}
+
+func newFlagSet(name, args string) *cmdFlags {
+	f := newConfigFlagSet(name, args)
+	f.caseID = f.String("case", "", "案件ID，默认为default案件")
+	return f
+}
+
+// 仅含配置参数的参数集，用于与案件无关的命令
+func newConfigFlagSet(name, args string) *cmdFlags {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "用法: aptrace %s %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return &cmdFlags{FlagSet: fs, config: config.RegisterFlags(fs)}
+}
+
+// 加载配置并打开分析存储
+func (f *cmdFlags) openStores() (analyzePipe.Stores, error) {
+	cfg, err := f.config.Load()
+	if err != nil {
+		return analyzePipe.Stores{}, err
+	}
+	if err := bootstrap.LoadDetection(cfg); err != nil {
+		return analyzePipe.Stores{}, err
+	}
+	return bootstrap.OpenStores(cfg)
+}
+
+// 解析案件参数，需在打开存储后调用
+func (f *cmdFlags) resolveCase() (uint, error) {
+	return utils.ResolveCase(utils.LogDB, *f.caseID)
+}
+
+// 先取出动词再解析参数，使参数可写在动词之后
+func parseVerb(fs *cmdFlags, args []string) ([]string, error) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		return fs.Args(), nil
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return nil, err
+	}
+	return append([]string{args[0]}, fs.Args()...), nil
+}
+
+func runMigrate(_ context.Context, args []string) error {
+	fs := newConfigFlagSet("migrate", "<status|up|down> [参数] [版本|数量]")
+	args, err := parseVerb(fs, args)
+	if err != nil {
+		return err
+	}
+	cfg, err := fs.config.Load()
+	if err != nil {
+		return err
+	}
+	if err := bootstrap.OpenDatabase(cfg); err != nil {
+		return err
+	}
+	return bootstrap.RunMigrate(args)
+}
+
+func runConfig(_ context.Context, args []string) error {
+	fs := newConfigFlagSet("config", "<print|check> [参数]")
+	args, err := parseVerb(fs, args)
+	if err != nil {
+		return err
+	}
+	cfg, err := fs.config.Load()
+	if err != nil {
+		return err
+	}
+	return bootstrap.RunConfig(cfg, args)
+}
@@ -0,0 +1,285 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"awesomeProject1/backend/store"
+	"awesomeProject1/backend/utils"
+)
+
+// 默认配置文件，存在时自动加载
+const DefaultFile = "config/aptrace.yaml"
+
+// 运行配置，优先级：命令行参数 > 环境变量 > 配置文件 > 默认值
+type Config struct {
+	Server   ServerConfig      `yaml:"server"`
+	Database utils.DBConfig    `yaml:"database"`
+	Graph    store.GraphConfig `yaml:"graph"`
+	Files    FilesConfig       `yaml:"files"`
+	Ingest   IngestConfig      `yaml:"ingest"`
+
+	Source string `yaml:"-"` // 实际加载的配置文件，未加载时为空
+}
+
+type ServerConfig struct {
+	Listen      string `yaml:"listen"`      // 监听地址
+	FrontendDir string `yaml:"frontendDir"` // 前端静态文件目录
+	UploadDir   string `yaml:"uploadDir"`   // 上传文件保存目录
+}
+
+// 检测配置文件路径
+type FilesConfig struct {
+	Thresholds  string `yaml:"thresholds"`
+	Rules       string `yaml:"rules"`
+	TcpProfiles string `yaml:"tcpProfiles"`
+}
+
+type IngestConfig struct {
+	BatchSize int `yaml:"batchSize"` // 批量写入条数
+}
+
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Listen:      ":8080",
+			FrontendDir: "frontend",
+			UploadDir:   "uploads",
+		},
+		// 默认使用MySQL且不提供连接串，未配置数据库时启动即报错；离线单机使用需显式选择sqlite
+		Database: utils.DBConfig{
+			Driver: utils.DriverMySQL,
+		},
+		Graph: store.GraphConfig{
+			Kind:     store.GraphAuto,
+			URI:      "bolt://localhost:7687",
+			Username: "neo4j",
+		},
+		Files: FilesConfig{
+			Thresholds:  "config/thresholds.yaml",
+			Rules:       "config/rules.yaml",
+			TcpProfiles: "config/tcp_profiles.yaml",
+		},
+		Ingest: IngestConfig{BatchSize: 1000},
+	}
+}
+
+// 可由环境变量与命令行参数覆盖的配置项
+type setting struct {
+	key   string // 配置文件中的路径
+	env   string
+	flag  string
+	usage string
+	set   func(c *Config, v string) error
+}
+
+func stringSetting(key, env, flagName, usage string, field func(c *Config) *string) setting {
+	return setting{key: key, env: env, flag: flagName, usage: usage, set: func(c *Config, v string) error {
+		*field(c) = v
+		return nil
+	}}
+}
+
+var settings = []setting{
+	stringSetting("server.listen", "APT_LISTEN", "listen", "监听地址",
+		func(c *Config) *string { return &c.Server.Listen }),
+	stringSetting("server.frontendDir", "APT_FRONTEND_DIR", "frontend-dir", "前端静态文件目录",
+		func(c *Config) *string { return &c.Server.FrontendDir }),
+	stringSetting("server.uploadDir", "APT_UPLOAD_DIR", "upload-dir", "上传文件保存目录",
+		func(c *Config) *string { return &c.Server.UploadDir }),
+	stringSetting("database.driver", "APT_DB_DRIVER", "db-driver", "数据库类型 mysql|sqlite",
+		func(c *Config) *string { return &c.Database.Driver }),
+	stringSetting("database.dsn", "APT_DB_DSN", "db-dsn", "数据库连接串",
+		func(c *Config) *string { return &c.Database.DSN }),
+	stringSetting("graph.store", "APT_GRAPH_STORE", "graph-store", "攻击图存储 auto|neo4j|sql",
+		func(c *Config) *string { return &c.Graph.Kind }),
+	stringSetting("graph.uri", "APT_NEO4J_URI", "neo4j-uri", "Neo4j连接地址",
+		func(c *Config) *string { return &c.Graph.URI }),
+	stringSetting("graph.username", "APT_NEO4J_USER", "neo4j-user", "Neo4j用户名",
+		func(c *Config) *string { return &c.Graph.Username }),
+	stringSetting("graph.password", "APT_NEO4J_PASSWORD", "neo4j-password", "Neo4j密码",
+		func(c *Config) *string { return &c.Graph.Password }),
+	stringSetting("files.thresholds", "APT_THRESHOLDS_FILE", "thresholds", "检测阈值文件",
+		func(c *Config) *string { return &c.Files.Thresholds }),
+	stringSetting("files.rules", "APT_RULES_FILE", "rules", "检测规则文件",
+		func(c *Config) *string { return &c.Files.Rules }),
+	stringSetting("files.tcpProfiles", "APT_TCP_PROFILES_FILE", "tcp-profiles", "TCP列映射方案文件",
+		func(c *Config) *string { return &c.Files.TcpProfiles }),
+	{key: "ingest.batchSize", env: "APT_INGEST_BATCH", flag: "ingest-batch", usage: "批量写入条数",
+		set: func(c *Config, v string) error {
+			size, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("不是整数: %s", v)
+			}
+			c.Ingest.BatchSize = size
+			return nil
+		}},
+}
+
+// 配置加载器，持有注册到命令行的配置参数
+type Loader struct {
+	fs    *flag.FlagSet
+	file  *string
+	flags map[string]*string
+}
+
+// 在fs上注册-config及各配置项参数
+func RegisterFlags(fs *flag.FlagSet) *Loader {
+	l := &Loader{
+		fs:    fs,
+		file:  fs.String("config", "", "配置文件，默认读取环境变量APT_CONFIG或"+DefaultFile),
+		flags: make(map[string]*string, len(settings)),
+	}
+	for _, s := range settings {
+		l.flags[s.flag] = fs.String(s.flag, "", s.usage+"（"+s.env+"）")
+	}
+	return l
+}
+
+// 依次合并默认值、配置文件、环境变量与已设置的命令行参数，并校验
+func (l *Loader) Load() (*Config, error) {
+	cfg := Default()
+
+	path, explicit := *l.file, true
+	if path == "" {
+		path = os.Getenv("APT_CONFIG")
+	}
+	if path == "" {
+		path, explicit = DefaultFile, false
+	}
+	if err := cfg.loadFile(path); err != nil {
+		if explicit || !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	} else {
+		cfg.Source = path
+	}
+
+	for _, s := range settings {
+		if v, ok := os.LookupEnv(s.env); ok && v != "" {
+			if err := s.set(&cfg, v); err != nil {
+				return nil, fmt.Errorf("环境变量%s无效: %v", s.env, err)
+			}
+		}
+	}
+
+	var flagErr error
+	l.fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag == f.Name && flagErr == nil {
+				if err := s.set(&cfg, *l.flags[s.flag]); err != nil {
+					flagErr = fmt.Errorf("参数-%s无效: %v", s.flag, err)
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, flagErr
+	}
+
+	if cfg.Database.Driver == utils.DriverSQLite && cfg.Database.DSN == "" {
+		cfg.Database.DSN = utils.DefaultSQLiteDSN
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("配置文件读取失败: %w", err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && err != io.EOF {
+		return fmt.Errorf("配置文件解析失败 %s: %v", path, err)
+	}
+	return nil
+}
+
+// 校验配置，返回全部问题
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	_, _, err := net.SplitHostPort(c.Server.Listen)
+	check(err == nil, "server.listen无效: %q", c.Server.Listen)
+	check(c.Server.FrontendDir != "", "server.frontendDir不能为空")
+	check(c.Server.UploadDir != "", "server.uploadDir不能为空")
+
+	switch c.Database.Driver {
+	case utils.DriverMySQL:
+		check(c.Database.DSN != "", "使用mysql时必须配置database.dsn（APT_DB_DSN），或将database.driver设为sqlite")
+	case utils.DriverSQLite:
+	default:
+		check(false, "database.driver不支持: %q", c.Database.Driver)
+	}
+
+	switch c.Graph.Kind {
+	case store.GraphNeo4j, store.GraphAuto:
+		check(c.Graph.URI != "", "使用Neo4j时必须配置graph.uri")
+		check(c.Graph.Username != "", "使用Neo4j时必须配置graph.username")
+	case store.GraphSQL:
+	default:
+		check(false, "graph.store不支持: %q", c.Graph.Kind)
+	}
+
+	check(c.Files.Thresholds != "" && c.Files.Rules != "" && c.Files.TcpProfiles != "", "files下的文件路径不能为空")
+	check(c.Ingest.BatchSize > 0, "ingest.batchSize必须为正数")
+
+	if len(problems) > 0 {
+		return fmt.Errorf("配置无效: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// 以YAML输出生效的配置，密码等敏感信息打码
+func (c *Config) Print(w io.Writer) error {
+	masked := *c
+	if masked.Graph.Password != "" {
+		masked.Graph.Password = "******"
+	}
+	masked.Database.DSN = maskDSN(masked.Database.DSN)
+
+	source := c.Source
+	if source == "" {
+		source = "无（使用默认值）"
+	}
+	if _, err := fmt.Fprintf(w, "# 配置文件: %s\n", source); err != nil {
+		return err
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&masked); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// 隐藏连接串中的密码（user:password@...）
+func maskDSN(dsn string) string {
+	at := strings.LastIndex(dsn, "@")
+	if at < 0 {
+		return dsn
+	}
+	colon := strings.Index(dsn[:at], ":")
+	if colon < 0 {
+		return dsn
+	}
+	return dsn[:colon+1] + "******" + dsn[at:]
+}
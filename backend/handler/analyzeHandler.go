@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"awesomeProject1/backend/analyzePipe"
+	"awesomeProject1/backend/utils"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+)
+
+// 对案件已导入的日志重新运行分析，可指定分析时间窗口
+func AnalyzeHandler(c *gin.Context) {
+	var req struct {
+		CaseID           uint     `json:"caseId"`
+		Start            string   `json:"start"`       // 为空时取数据集最早时间
+		End              string   `json:"end"`         // 为空时取数据集最晚时间
+		Incremental      bool     `json:"incremental"` // 仅分析上次分析后入库的数据
+		EnableDetectors  []string `json:"enableDetectors"`
+		DisableDetectors []string `json:"disableDetectors"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("参数绑定错误: %v", err)
+		errorResponse(c, http.StatusBadRequest, "无效请求参数")
+		return
+	}
+
+	caseID, err := utils.LookupCase(utils.LogDB, req.CaseID)
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	window, err := analyzePipe.ParseWindow(req.Start, req.End)
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := checkDetectorNames(req.EnableDetectors, req.DisableDetectors); err != nil {
+		errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	job := Jobs.Create()
+	opts := analyzePipe.PipelineOptions{
+		CaseID:            caseID,
+		Window:            window,
+		Incremental:       req.Incremental,
+		EnabledDetectors:  req.EnableDetectors,
+		DisabledDetectors: req.DisableDetectors,
+		OnStage:           job.SetPhase,
+	}
+	go func() {
+		defer job.Finish()
+		ctx := job.Context()
+		if err := analyzePipe.AnalyzePipeline(ctx, AnalysisStores, opts); err != nil && ctx.Err() == nil {
+			job.AddError(err)
+		}
+	}()
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status": "success",
+		"data":   gin.H{"jobId": job.ID(), "caseId": caseID},
+	})
+}
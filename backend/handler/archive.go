@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nwaples/rardecode/v2"
+)
+
+const (
+	detectPeekSize    = 64 * 1024 // 类型识别时预读的字节数
+	detectSampleLines = 10        // 类型识别时最多检查的非空行数，跳过开头个别格式错误的行
+)
+
+// 根据扩展名识别压缩格式，非压缩文件返回空串
+func archiveKind(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return "tar.gz"
+	case strings.HasSuffix(lower, ".tar"):
+		return "tar"
+	case strings.HasSuffix(lower, ".gz"):
+		return "gz"
+	case strings.HasSuffix(lower, ".zip"):
+		return "zip"
+	case strings.HasSuffix(lower, ".rar"):
+		return "rar"
+	}
+	return ""
+}
+
+// 按内容识别日志类型，无法识别时返回空串：首行为会话日志表头，或样本行能按选定的列映射解析时为tcp，
+// 符合攻击日志格式时为attack。不按列数判断，自定义列映射的会话日志列数可能与攻击日志相近
+func detectLogType(br *bufio.Reader, profile *TcpColumnProfile) string {
+	data, err := br.Peek(detectPeekSize)
+	lines := strings.Split(string(data), "\n")
+	if err == nil {
+		lines = lines[:len(lines)-1] // 预读缓冲区末尾的行可能不完整
+	}
+	sampled := 0
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if sampled == 0 && profileFromHeader(line, profile) != nil {
+			return "tcp"
+		}
+		if _, err := parseAttackLine(line); err == nil {
+			return "attack"
+		}
+		if _, err := profile.parse(line); err == nil {
+			return "tcp"
+		}
+		if sampled++; sampled >= detectSampleLines {
+			break
+		}
+	}
+	return ""
+}
+
+// 逐个解压压缩包成员并回调，成员内容以流的形式提供，不落盘
+func walkArchive(path string, fn func(member string, r io.Reader) error) error {
+	switch archiveKind(path) {
+	case "zip":
+		zr, err := zip.OpenReader(path)
+		if err != nil {
+			return fmt.Errorf("zip打开失败: %v", err)
+		}
+		defer zr.Close()
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return fmt.Errorf("zip成员%s打开失败: %v", f.Name, err)
+			}
+			err = fn(f.Name, rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case "rar":
+		rr, err := rardecode.OpenReader(path)
+		if err != nil {
+			return fmt.Errorf("rar打开失败: %v", err)
+		}
+		defer rr.Close()
+		for {
+			h, err := rr.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("rar读取失败: %v", err)
+			}
+			if h.IsDir {
+				continue
+			}
+			if err := fn(h.Name, rr); err != nil {
+				return err
+			}
+		}
+
+	case "gz", "tar.gz", "tar":
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("文件打开失败: %v", err)
+		}
+		defer file.Close()
+
+		var r io.Reader = file
+		member := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		if archiveKind(path) != "tar" {
+			gz, err := gzip.NewReader(file)
+			if err != nil {
+				return fmt.Errorf("gzip打开失败: %v", err)
+			}
+			defer gz.Close()
+			if gz.Name != "" {
+				member = gz.Name
+			}
+			if archiveKind(path) == "gz" {
+				return fn(member, gz)
+			}
+			r = gz
+		}
+
+		tr := tar.NewReader(r)
+		for {
+			h, err := tr.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("tar读取失败: %v", err)
+			}
+			if h.Typeflag != tar.TypeReg {
+				continue
+			}
+			if err := fn(h.Name, tr); err != nil {
+				return err
+			}
+		}
+	}
+	return fmt.Errorf("不支持的压缩格式: %s", path)
+}
+
+// 解析压缩包内的日志，hint为上传时声明的类型；未声明时按内容识别各成员类型
+func IngestArchive(ctx context.Context, path string, hint string, opts IngestOptions) ([]IngestSummary, error) {
+	ingester, err := newIngesterWithOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	record, skip, err := registerLogFile(ingester.db, path, ingester.CaseID, opts.UploadID, opts.Replace)
+	if err != nil {
+		return nil, err
+	}
+	if skip {
+		return []IngestSummary{{File: filepath.Base(path), Type: "archive", Skipped: true, Quality: 1}}, nil
+	}
+	ingester.FileID = record.ID
+
+	var summaries []IngestSummary
+	err = walkArchive(path, func(member string, r io.Reader) error {
+		name := filepath.Base(path) + ":" + member
+		br := bufio.NewReaderSize(r, detectPeekSize)
+
+		// 声明了类型时以声明为准，自定义列映射的会话日志列数可能少于按列数识别的下限
+		logType := hint
+		if logType == "" {
+			logType = detectLogType(br, ingester.TcpProfile)
+		}
+		if logType == "" {
+			log.Printf("无法识别日志类型，跳过: %s", name)
+			summaries = append(summaries, IngestSummary{File: name, Type: "unknown"})
+			return nil
+		}
+
+		log.Printf("正在处理压缩包成员: %s (%s)", name, logType)
+		summary, err := ingester.Ingest(ctx, br, name, logType)
+		summaries = append(summaries, summary)
+		return err
+	})
+	finishLogFile(ingester.db, record, summaries, err)
+	return summaries, err
+}
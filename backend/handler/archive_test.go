@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testMembers = []struct{ name, body string }{
+	{"logs/attack.log", "attack content\n"},
+	{"logs/tcp.log", "tcp content\n"},
+}
+
+func writeTestArchive(t *testing.T, name string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	var buf bytes.Buffer
+	switch archiveKind(name) {
+	case "zip":
+		zw := zip.NewWriter(&buf)
+		if _, err := zw.Create("logs/"); err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range testMembers {
+			w, err := zw.Create(m.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			io.WriteString(w, m.body)
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	case "tar", "tar.gz":
+		var w io.Writer = &buf
+		var gz *gzip.Writer
+		if archiveKind(name) == "tar.gz" {
+			gz = gzip.NewWriter(&buf)
+			w = gz
+		}
+		tw := tar.NewWriter(w)
+		tw.WriteHeader(&tar.Header{Name: "logs/", Typeflag: tar.TypeDir, Mode: 0755})
+		for _, m := range testMembers {
+			tw.WriteHeader(&tar.Header{Name: m.name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(m.body))})
+			io.WriteString(tw, m.body)
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if gz != nil {
+			gz.Close()
+		}
+	case "gz":
+		gz := gzip.NewWriter(&buf)
+		io.WriteString(gz, testMembers[0].body)
+		gz.Close()
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestArchiveKind(t *testing.T) {
+	tests := map[string]string{
+		"a.tar.gz": "tar.gz",
+		"A.TGZ":    "tar.gz",
+		"a.tar":    "tar",
+		"a.log.gz": "gz",
+		"a.zip":    "zip",
+		"a.rar":    "rar",
+		"a.log":    "",
+	}
+	for name, want := range tests {
+		if got := archiveKind(name); got != want {
+			t.Errorf("archiveKind(%s) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestWalkArchive(t *testing.T) {
+	all := map[string]string{}
+	for _, m := range testMembers {
+		all[m.name] = m.body
+	}
+	tests := []struct {
+		name string
+		want map[string]string
+	}{
+		{"logs.zip", all},
+		{"logs.tar", all},
+		{"logs.tar.gz", all},
+		{"logs.tgz", all},
+		// 单文件gzip以去掉扩展名的文件名作为成员名
+		{"attack.log.gz", map[string]string{"attack.log": testMembers[0].body}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := map[string]string{}
+			err := walkArchive(writeTestArchive(t, tt.name), func(member string, r io.Reader) error {
+				data, err := io.ReadAll(r)
+				got[member] = string(data)
+				return err
+			})
+			if err != nil {
+				t.Fatalf("walkArchive() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("walkArchive() 成员 = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWalkArchiveErrors(t *testing.T) {
+	stop := errors.New("stop")
+	calls := 0
+	err := walkArchive(writeTestArchive(t, "logs.zip"), func(string, io.Reader) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("回调出错后 error = %v, calls = %d, want stop, 1", err, calls)
+	}
+
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "tcp.log")
+	broken := filepath.Join(dir, "broken.tar.gz")
+	os.WriteFile(plain, []byte("x"), 0644)
+	os.WriteFile(broken, []byte("not gzip"), 0644)
+	for _, path := range []string{plain, broken} {
+		if err := walkArchive(path, func(string, io.Reader) error { return nil }); err == nil {
+			t.Errorf("walkArchive(%s) 未返回错误", filepath.Base(path))
+		}
+	}
+}
+
+func TestDetectLogType(t *testing.T) {
+	const (
+		attackLine  = "0 2024-03-01 08:00:00 5 192.16.3.5 6 alert 192.168.3.29 1"
+		defaultLine = "0 2024-03-01 08:00:00 2024-03-01 08:00:00 2024-03-01 08:00:30 2024-03-01 08:00:00 1 30 " +
+			"192.168.3.29 80 192.168.3.30 6191 0 0 0 0 0 0 6 0 0 0 0 0 0 0 0 0 0 0 775408 258567 0 0 0 0 0"
+		isoLine   = "2024-03-01T08:00:00 2024-03-01T08:01:00 192.168.3.30 50000 8.8.8.8 443 6 60 1000 2000"
+		isoHeader = "BEGINTIME ENDTIME CLIENTIP CLIENTPORT SERVERIP SERVERPORT"
+	)
+	iso := &TcpColumnProfile{Name: "iso", TimeFormat: "2006-01-02T15:04:05", Columns: map[string]int{
+		"start_time": 0, "end_time": 1, "client_ip": 2, "client_port": 3, "server_ip": 4, "server_port": 5,
+		"protocol": 6, "duration": 7, "up_bytes": 8, "down_bytes": 9,
+	}}
+	if err := iso.compile(); err != nil {
+		t.Fatal(err)
+	}
+	def := defaultTcpProfile()
+
+	tests := []struct {
+		name    string
+		data    string
+		profile *TcpColumnProfile
+		want    string
+	}{
+		{"内置格式会话日志", defaultLine + "\n", def, "tcp"},
+		{"攻击日志", attackLine + "\n", def, "attack"},
+		{"选定自定义列映射的会话日志", isoLine + "\n" + isoLine, iso, "tcp"},
+		// 10列的会话日志不应因列数达到攻击日志下限被识别为攻击日志
+		{"未选定列映射的自定义会话日志", isoLine + "\n", def, ""},
+		{"会话日志表头", isoHeader + "\n" + isoLine, def, "tcp"},
+		{"跳过开头空行与格式错误的行", "\n  \nbroken line\n" + attackLine, def, "attack"},
+		{"无法解析", strings.Repeat("f ", 9) + "\n" + strings.Repeat("f ", 40), def, ""},
+		{"空文件", "", def, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectLogType(bufio.NewReader(strings.NewReader(tt.data)), tt.profile); got != tt.want {
+				t.Errorf("detectLogType() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectLogTypeIgnoresTruncatedLine(t *testing.T) {
+	// 严重等级列为1x，整行不是攻击日志；预读恰好在1之后截断时截断的行不参与识别
+	line := "0 2024-03-01 08:00:00 5 192.16.3.5 6 alert 192.168.3.29 1x\n"
+	data := strings.Repeat("\n", detectPeekSize-len(line)+2) + line
+	if got := detectLogType(bufio.NewReaderSize(strings.NewReader(data), detectPeekSize), defaultTcpProfile()); got != "" {
+		t.Errorf("detectLogType() = %q, want \"\"", got)
+	}
+}
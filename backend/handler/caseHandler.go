@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"awesomeProject1/backend/utils"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// 列出全部案件
+func ListCasesHandler(c *gin.Context) {
+	var cases []utils.Case
+	if err := utils.LogDB.Order("id asc").Find(&cases).Error; err != nil {
+		log.Printf("查询失败: %v", err)
+		errorResponse(c, http.StatusInternalServerError, "数据获取失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   cases,
+	})
+}
+
+// 新建案件
+func CreateCaseHandler(c *gin.Context) {
+	var req struct {
+		Name        string `json:"name" binding:"required"`
+		Description string `json:"description"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效请求参数")
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		errorResponse(c, http.StatusBadRequest, "案件名称不能为空")
+		return
+	}
+	var count int64
+	if err := utils.LogDB.Model(&utils.Case{}).Where("name = ?", name).Count(&count).Error; err != nil {
+		log.Printf("查询失败: %v", err)
+		errorResponse(c, http.StatusInternalServerError, "案件创建失败")
+		return
+	}
+	if count > 0 {
+		errorResponse(c, http.StatusConflict, "案件名称已存在: "+name)
+		return
+	}
+
+	record := utils.Case{Name: name, Description: req.Description}
+	if err := utils.LogDB.Create(&record).Error; err != nil {
+		log.Printf("案件创建失败: %v", err)
+		errorResponse(c, http.StatusInternalServerError, "案件创建失败")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status": "success",
+		"data":   record,
+	})
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"awesomeProject1/backend/model"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+)
+
+func ListDetectorsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   model.DefaultRegistry.List(),
+	})
+}
+
+func UpdateDetectorHandler(c *gin.Context) {
+	var req struct {
+		Enabled *bool `json:"enabled" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("参数绑定错误: %v", err)
+		errorResponse(c, http.StatusBadRequest, "无效请求参数")
+		return
+	}
+
+	name := c.Param("name")
+	if err := model.DefaultRegistry.SetEnabled(name, *req.Enabled); err != nil {
+		errorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   gin.H{"name": name, "enabled": *req.Enabled},
+	})
+}
+
+// 校验单次运行启用/禁用的规则名均已注册
+func checkDetectorNames(lists ...[]string) error {
+	for _, names := range lists {
+		for _, name := range names {
+			if !model.DefaultRegistry.Has(name) {
+				return fmt.Errorf("检测规则不存在: %s", name)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"awesomeProject1/backend/utils"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// 查询事件的证据：触发分析的攻击日志与规则命中的TCP会话（会话分页），事件须属于caseId指定的案件
+func EventEvidenceHandler(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效事件ID")
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+
+	caseID, err := utils.ResolveCase(utils.LogDB, c.Query("caseId"))
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	event, err := quaryAptEventByID(caseID, uint(id))
+	if err != nil {
+		log.Printf("查询失败: %v", err)
+		errorResponse(c, http.StatusNotFound, "未找到相关记录")
+		return
+	}
+
+	DB := utils.LogDB
+	evidenceIDs := func(logType string) interface{} {
+		return DB.Model(&utils.EventEvidence{}).Select("log_id").
+			Where("event_id = ? AND log_type = ?", event.ID, logType)
+	}
+
+	var attacks []utils.AttackLog
+	if err := DB.Where("case_id = ? AND id IN (?)", event.CaseID, evidenceIDs(utils.EvidenceAttack)).
+		Order("log_time asc").Find(&attacks).Error; err != nil {
+		log.Printf("查询失败: %v", err)
+		errorResponse(c, http.StatusInternalServerError, "数据获取失败")
+		return
+	}
+
+	flowQuery := DB.Model(&utils.TcpLog{}).Where("case_id = ? AND id IN (?)", event.CaseID, evidenceIDs(utils.EvidenceTcp))
+	var total int64
+	if err := flowQuery.Count(&total).Error; err != nil {
+		log.Printf("查询失败: %v", err)
+		errorResponse(c, http.StatusInternalServerError, "数据获取失败")
+		return
+	}
+	var flows []utils.TcpLog
+	if err := flowQuery.Order("start_time asc").Offset((page - 1) * limit).Limit(limit).Find(&flows).Error; err != nil {
+		log.Printf("查询失败: %v", err)
+		errorResponse(c, http.StatusInternalServerError, "数据获取失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"event":      event,
+			"attackLogs": attacks,
+			"tcpTotal":   total,
+			"tcpLogs":    flows,
+		},
+	})
+}
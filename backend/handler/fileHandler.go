@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"awesomeProject1/backend/utils"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+)
+
+// 列出案件内已导入的日志文件及其导入统计
+func ListLogFilesHandler(c *gin.Context) {
+	caseID, err := utils.ResolveCase(utils.LogDB, c.Query("caseId"))
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var files []utils.LogFile
+	if err := utils.LogDB.Where("case_id = ?", caseID).Order("id desc").Find(&files).Error; err != nil {
+		log.Printf("查询失败: %v", err)
+		errorResponse(c, http.StatusInternalServerError, "数据获取失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   files,
+	})
+}
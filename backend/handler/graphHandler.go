@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"awesomeProject1/backend/utils"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+)
+
+// 获取案件的攻击图（节点与阶段转移），不依赖图存储类型
+func GraphHandler(c *gin.Context) {
+	caseID, err := utils.ResolveCase(utils.LogDB, c.Query("caseId"))
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if AnalysisStores.Graph == nil {
+		errorResponse(c, http.StatusServiceUnavailable, "图存储未初始化")
+		return
+	}
+
+	nodes, edges, err := AnalysisStores.Graph.LoadGraph(caseID)
+	if err != nil {
+		log.Printf("查询失败: %v", err)
+		errorResponse(c, http.StatusInternalServerError, "数据获取失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"nodes": nodes,
+			"edges": edges,
+		},
+	})
+}
@@ -0,0 +1,311 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"awesomeProject1/backend/utils"
+)
+
+const (
+	defaultBatchSize = 1000    // 默认批量写入条数
+	maxLineSize      = 1 << 20 // 单行最大长度
+	dedupWindowSize  = 1 << 20 // 行去重窗口（保留的行哈希数量）
+	progressInterval = 10000   // 进度回调间隔（行）
+)
+
+// 批量写入条数，可由启动参数调整
+var IngestBatchSize = defaultBatchSize
+
+// 单个文件的入库统计
+type IngestSummary struct {
+	File       string  `json:"file"`
+	Type       string  `json:"type"`
+	Lines      int     `json:"lines"`      // 读取行数
+	Accepted   int     `json:"accepted"`   // 成功入库
+	Rejected   int     `json:"rejected"`   // 解析失败
+	Duplicates int     `json:"duplicates"` // 重复行（文件内或已入库）
+	Failed     int     `json:"failed"`     // 写库失败
+	Quality    float64 `json:"quality"`    // 质量分（0-1）
+	Unreliable bool    `json:"unreliable"` // 质量分低于阈值
+	Skipped    bool    `json:"skipped"`    // 文件内容已导入，未重复处理
+	Elapsed    string  `json:"elapsed"`
+}
+
+// 入库选项
+type IngestOptions struct {
+	UploadID   string          // 上传任务ID，用于关联隔离记录
+	CaseID     uint            // 所属案件，0为默认案件
+	TcpProfile string          // TCP日志列映射方案，空为内置方案
+	Replace    bool            // 重新导入已存在的文件（先删除旧数据）
+	OnProgress func(lines int) // 读取进度回调（增量行数）
+}
+
+// 流式入库器：逐行解析，按批写库，内存占用与文件大小无关
+type Ingester struct {
+	db         *gorm.DB
+	batchSize  int
+	UploadID   string
+	CaseID     uint
+	FileID     uint
+	TcpProfile *TcpColumnProfile // 文件含表头时以表头为准
+	OnProgress func(lines int)   // 读取进度回调（增量行数）
+}
+
+func NewIngester(db *gorm.DB, batchSize int) *Ingester {
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	profile, _ := GetTcpProfile("")
+	return &Ingester{db: db, batchSize: batchSize, CaseID: utils.DefaultCaseID, TcpProfile: profile}
+}
+
+func newIngesterWithOptions(opts IngestOptions) (*Ingester, error) {
+	profile, err := GetTcpProfile(opts.TcpProfile)
+	if err != nil {
+		return nil, err
+	}
+	ingester := NewIngester(utils.LogDB, IngestBatchSize)
+	ingester.TcpProfile = profile
+	ingester.UploadID = opts.UploadID
+	if opts.CaseID != 0 {
+		ingester.CaseID = opts.CaseID
+	}
+	ingester.OnProgress = opts.OnProgress
+	return ingester, nil
+}
+
+// 有界的行去重集合，超过容量后清空重新计数
+type lineDedup struct {
+	seen map[uint64]struct{}
+}
+
+func newLineDedup() *lineDedup {
+	return &lineDedup{seen: make(map[uint64]struct{})}
+}
+
+func (d *lineDedup) duplicate(line string) bool {
+	h := fnv.New64a()
+	h.Write([]byte(line))
+	key := h.Sum64()
+	if _, exists := d.seen[key]; exists {
+		return true
+	}
+	if len(d.seen) >= dedupWindowSize {
+		d.seen = make(map[uint64]struct{})
+	}
+	d.seen[key] = struct{}{}
+	return false
+}
+
+// 批量写库缓冲，skipConflicts时按唯一键忽略已存在的行
+type batchWriter[T any] struct {
+	db            *gorm.DB
+	size          int
+	rows          []T
+	skipConflicts bool
+	onFlush       func(n int, inserted int64, err error)
+}
+
+func (w *batchWriter[T]) add(row T) {
+	w.rows = append(w.rows, row)
+	if len(w.rows) >= w.size {
+		w.flush()
+	}
+}
+
+func (w *batchWriter[T]) flush() {
+	if len(w.rows) == 0 {
+		return
+	}
+	db := w.db
+	if w.skipConflicts {
+		db = db.Clauses(clause.OnConflict{DoNothing: true})
+	}
+	result := db.CreateInBatches(w.rows, w.size)
+	w.onFlush(len(w.rows), result.RowsAffected, result.Error)
+	w.rows = w.rows[:0]
+}
+
+func (ing *Ingester) reportProgress(lines int) {
+	if ing.OnProgress != nil && lines > 0 {
+		ing.OnProgress(lines)
+	}
+}
+
+// 从输入流解析并入库，ctx取消时在已写入的批次后停止
+func (ing *Ingester) Ingest(ctx context.Context, r io.Reader, name, fileType string) (IngestSummary, error) {
+	begin := time.Now()
+	summary := IngestSummary{File: name, Type: fileType}
+	dedup := newLineDedup()
+
+	saveResult := func(n int, inserted int64, err error) {
+		if err != nil {
+			log.Printf("%s日志批量保存失败 (%d条): %v", fileType, n, err)
+			summary.Failed += n
+		} else {
+			summary.Accepted += int(inserted)
+			summary.Duplicates += n - int(inserted)
+		}
+	}
+	attackWriter := &batchWriter[utils.AttackLog]{db: ing.db, size: ing.batchSize, skipConflicts: true, onFlush: saveResult}
+	tcpWriter := &batchWriter[utils.TcpLog]{db: ing.db, size: ing.batchSize, skipConflicts: true, onFlush: saveResult}
+	quarantine := &batchWriter[utils.QuarantinedLine]{db: ing.db, size: ing.batchSize, onFlush: func(n int, _ int64, err error) {
+		if err != nil {
+			log.Printf("隔离记录保存失败 (%d条): %v", n, err)
+		}
+	}}
+	reject := func(line string, err error) {
+		summary.Rejected++
+		quarantine.add(utils.QuarantinedLine{
+			UploadID: ing.UploadID,
+			CaseID:   ing.CaseID,
+			FileID:   ing.FileID,
+			File:     name,
+			LogType:  fileType,
+			LineNo:   summary.Lines,
+			Reason:   err.Error(),
+			Content:  truncateContent(line),
+		})
+	}
+	flushAll := func() {
+		attackWriter.flush()
+		tcpWriter.flush()
+		quarantine.flush()
+	}
+
+	profile := ing.TcpProfile
+	headerChecked := fileType != "tcp"
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+
+	reported := 0
+	for scanner.Scan() {
+		summary.Lines++
+		if summary.Lines%progressInterval == 0 {
+			ing.reportProgress(summary.Lines - reported)
+			reported = summary.Lines
+			if err := ctx.Err(); err != nil {
+				flushAll()
+				return summary, err
+			}
+		}
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if !headerChecked {
+			headerChecked = true
+			if p := profileFromHeader(line, profile); p != nil {
+				log.Printf("识别到表头，使用列映射: %s", name)
+				profile = p
+				continue
+			}
+		}
+		if dedup.duplicate(line) {
+			summary.Duplicates++
+			continue
+		}
+
+		switch fileType {
+		case "attack":
+			if logData, err := parseAttackLine(line); err == nil {
+				logData.CaseID = ing.CaseID
+				logData.FileID = ing.FileID
+				logData.RowKey = logData.NaturalKey()
+				attackWriter.add(logData)
+			} else {
+				reject(line, err)
+			}
+		case "tcp":
+			if logData, err := profile.parse(line); err == nil {
+				logData.CaseID = ing.CaseID
+				logData.FileID = ing.FileID
+				logData.RowKey = logData.NaturalKey()
+				tcpWriter.add(logData)
+			} else {
+				reject(line, err)
+			}
+		default:
+			return summary, fmt.Errorf("未知日志类型: %s", fileType)
+		}
+	}
+	flushAll()
+	ing.reportProgress(summary.Lines - reported)
+
+	summary.Quality = qualityScore(summary.Accepted+summary.Failed, summary.Rejected)
+	summary.Unreliable = summary.Quality < qualityWarnThreshold
+	summary.Elapsed = time.Since(begin).Round(time.Millisecond).String()
+	log.Printf("文件入库完成 %s: 行%d 入库%d 拒绝%d 重复%d 失败%d 质量%.2f (%s)",
+		name, summary.Lines, summary.Accepted, summary.Rejected, summary.Duplicates, summary.Failed, summary.Quality, summary.Elapsed)
+
+	if err := scanner.Err(); err != nil {
+		return summary, fmt.Errorf("文件读取错误: %v", err)
+	}
+	return summary, nil
+}
+
+func ParseAndSaveLogFile(ctx context.Context, fileName string, fileType string, opts IngestOptions) (IngestSummary, error) {
+	ingester, err := newIngesterWithOptions(opts)
+	if err != nil {
+		return IngestSummary{File: fileName, Type: fileType}, err
+	}
+
+	file, err := os.Open(fileName)
+	if err != nil {
+		return IngestSummary{File: fileName, Type: fileType}, fmt.Errorf("文件打开失败: %v", err)
+	}
+	defer file.Close()
+
+	record, skip, err := registerLogFile(ingester.db, fileName, ingester.CaseID, opts.UploadID, opts.Replace)
+	if err != nil {
+		return IngestSummary{File: fileName, Type: fileType}, err
+	}
+	if skip {
+		return IngestSummary{File: fileName, Type: fileType, Skipped: true, Quality: 1}, nil
+	}
+	ingester.FileID = record.ID
+
+	summary, err := ingester.Ingest(ctx, file, fileName, fileType)
+	finishLogFile(ingester.db, record, []IngestSummary{summary}, err)
+	return summary, err
+}
+
+// 导入单个文件：压缩包逐个成员导入，其余按logType解析。
+// logType为空或archive时按内容识别类型
+func IngestPath(ctx context.Context, path string, logType string, opts IngestOptions) ([]IngestSummary, error) {
+	if logType == "archive" {
+		logType = ""
+	}
+	if archiveKind(path) != "" {
+		return IngestArchive(ctx, path, logType, opts)
+	}
+	if logType == "" {
+		profile, err := GetTcpProfile(opts.TcpProfile)
+		if err != nil {
+			return nil, err
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("文件打开失败: %v", err)
+		}
+		logType = detectLogType(bufio.NewReaderSize(file, detectPeekSize), profile)
+		file.Close()
+		if logType == "" {
+			return []IngestSummary{{File: path}}, fmt.Errorf("无法识别日志类型: %s", path)
+		}
+	}
+	summary, err := ParseAndSaveLogFile(ctx, path, logType, opts)
+	return []IngestSummary{summary}, err
+}
@@ -18,7 +18,13 @@ func RefreshHandler(c *gin.Context) {
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
 	offset := (page - 1) * limit
 
-	aptEvents, total, err := quaryAll(offset, limit)
+	caseID, err := utils.ResolveCase(utils.LogDB, c.Query("caseId"))
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	aptEvents, total, err := quaryAll(caseID, offset, limit)
 	if err != nil {
 		log.Printf("查询失败: %v", err)
 		errorResponse(c, http.StatusInternalServerError, "数据获取失败")
@@ -36,7 +42,8 @@ func RefreshHandler(c *gin.Context) {
 
 func InquireHandler(c *gin.Context) {
 	var queryParams struct {
-		ID uint `json:"id" binding:"required"`
+		ID     uint `json:"id" binding:"required"`
+		CaseID uint `json:"caseId"` // 0为默认案件
 	}
 
 	if err := c.ShouldBindJSON(&queryParams); err != nil {
@@ -45,7 +52,13 @@ func InquireHandler(c *gin.Context) {
 		return
 	}
 
-	aptEvent, err := quaryAptEventByID(queryParams.ID)
+	caseID, err := utils.LookupCase(utils.LogDB, queryParams.CaseID)
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	aptEvent, err := quaryAptEventByID(caseID, queryParams.ID)
 	if err != nil {
 		log.Printf("查询失败: %v", err)
 		errorResponse(c, http.StatusNotFound, "未找到相关记录")
@@ -58,27 +71,28 @@ func InquireHandler(c *gin.Context) {
 	})
 }
 
-func quaryAll(offset, limit int) ([]utils.APTEvent, int64, error) {
+func quaryAll(caseID uint, offset, limit int) ([]utils.APTEvent, int64, error) {
 	var aptEvents []utils.APTEvent
 	var total int64
 	DB := utils.LogDB
 
 	// 获取总数
-	DB.Model(&utils.APTEvent{}).Count(&total)
+	DB.Model(&utils.APTEvent{}).Where("case_id = ?", caseID).Count(&total)
 
 	// 分页查询
-	result := DB.Order("created_at desc").Offset(offset).Limit(limit).Find(&aptEvents)
+	result := DB.Where("case_id = ?", caseID).Order("created_at desc").Offset(offset).Limit(limit).Find(&aptEvents)
 	if result.Error != nil {
 		return nil, 0, result.Error
 	}
 	return aptEvents, total, nil
 }
 
-func quaryAptEventByID(id uint) (utils.APTEvent, error) {
+// 按ID查询案件内的事件，其他案件的事件视为不存在
+func quaryAptEventByID(caseID, id uint) (utils.APTEvent, error) {
 	var aptEvent utils.APTEvent
 	DB := utils.LogDB
 
-	result := DB.First(&aptEvent, id)
+	result := DB.Where("case_id = ?", caseID).First(&aptEvent, id)
 	if result.Error != nil {
 		return utils.APTEvent{}, result.Error
 	}
@@ -86,8 +100,9 @@ func quaryAptEventByID(id uint) (utils.APTEvent, error) {
 }
 
 type PageRequest struct {
-	Page     int `form:"page"`     // 当前页码（从1开始）
-	PageSize int `form:"pageSize"` // 每页数量（固定50）
+	Page     int  `form:"page"`     // 当前页码（从1开始）
+	PageSize int  `form:"pageSize"` // 每页数量（固定50）
+	CaseID   uint `form:"caseId"`   // 案件ID，0为默认案件
 }
 
 type PaginatedResponse struct {
@@ -115,14 +130,21 @@ func QuaryAPTEvents(c *gin.Context) {
 		req.PageSize = 50 // 默认每页50条
 	}
 
+	caseID, err := utils.LookupCase(DB, req.CaseID)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
 	var events []utils.APTEvent
 	var totalCount int64
 
 	// 获取总数
-	DB.Model(&utils.APTEvent{}).Count(&totalCount)
+	DB.Model(&utils.APTEvent{}).Where("case_id = ?", caseID).Count(&totalCount)
 
 	// 执行分页查询
-	err := DB.Model(&utils.APTEvent{}).
+	err = DB.Model(&utils.APTEvent{}).
+		Where("case_id = ?", caseID).
 		Order("created_at DESC"). // 按创建时间倒序
 		Limit(req.PageSize).
 		Offset((req.Page - 1) * req.PageSize).
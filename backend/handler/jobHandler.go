@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+func ListJobsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   Jobs.List(),
+	})
+}
+
+func JobStatusHandler(c *gin.Context) {
+	job, ok := Jobs.Get(c.Param("id"))
+	if !ok {
+		errorResponse(c, http.StatusNotFound, "任务不存在")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   job.Status(),
+	})
+}
+
+func CancelJobHandler(c *gin.Context) {
+	job, ok := Jobs.Get(c.Param("id"))
+	if !ok {
+		errorResponse(c, http.StatusNotFound, "任务不存在")
+		return
+	}
+
+	job.Cancel()
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   job.Status(),
+	})
+}
@@ -0,0 +1,210 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 任务阶段（分析阶段见analyzePipe）
+const (
+	JobSaving    = "saving"
+	JobParsing   = "parsing"
+	JobDone      = "done"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+const jobRetention = 24 * time.Hour // 已结束任务的保留时间
+
+// 上传分析任务
+type Job struct {
+	mu         sync.RWMutex
+	id         string
+	phase      string
+	files      []IngestSummary
+	errors     []string
+	startedAt  time.Time
+	finishedAt time.Time
+	linesRead  atomic.Int64
+	ctx        context.Context
+	cancel     context.CancelFunc
+}
+
+// 任务状态快照（API返回）
+type JobStatus struct {
+	ID         string          `json:"id"`
+	Phase      string          `json:"phase"`
+	LinesRead  int64           `json:"linesRead"`
+	Quality    float64         `json:"quality"`    // 全部文件的质量分
+	Unreliable bool            `json:"unreliable"` // 质量分低于阈值
+	Files      []IngestSummary `json:"files"`
+	Errors     []string        `json:"errors"`
+	StartedAt  time.Time       `json:"startedAt"`
+	FinishedAt *time.Time      `json:"finishedAt,omitempty"`
+	Elapsed    string          `json:"elapsed"`
+}
+
+func (j *Job) ID() string { return j.id }
+
+func (j *Job) Context() context.Context { return j.ctx }
+
+func (j *Job) SetPhase(phase string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if !j.finishedAt.IsZero() {
+		return
+	}
+	j.phase = phase
+}
+
+func (j *Job) AddLines(n int) {
+	j.linesRead.Add(int64(n))
+}
+
+func (j *Job) AddFile(summary IngestSummary) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.files = append(j.files, summary)
+}
+
+func (j *Job) AddError(err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.errors = append(j.errors, err.Error())
+}
+
+// 结束任务，根据上下文和错误确定最终状态
+func (j *Job) Finish() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if !j.finishedAt.IsZero() {
+		return
+	}
+	switch {
+	case j.ctx.Err() != nil:
+		j.phase = JobCancelled
+	case len(j.errors) > 0:
+		j.phase = JobFailed
+	default:
+		j.phase = JobDone
+	}
+	j.finishedAt = time.Now()
+	j.cancel()
+}
+
+func (j *Job) Cancel() {
+	j.cancel()
+}
+
+func (j *Job) Status() JobStatus {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	status := JobStatus{
+		ID:        j.id,
+		Phase:     j.phase,
+		LinesRead: j.linesRead.Load(),
+		Files:     append([]IngestSummary(nil), j.files...),
+		Errors:    append([]string(nil), j.errors...),
+		StartedAt: j.startedAt,
+	}
+	var parsed, rejected int
+	for _, f := range j.files {
+		parsed += f.Accepted + f.Failed
+		rejected += f.Rejected
+	}
+	status.Quality = qualityScore(parsed, rejected)
+	status.Unreliable = status.Quality < qualityWarnThreshold
+
+	end := time.Now()
+	if !j.finishedAt.IsZero() {
+		finished := j.finishedAt
+		status.FinishedAt = &finished
+		end = finished
+	}
+	status.Elapsed = end.Sub(j.startedAt).Round(time.Millisecond).String()
+	return status
+}
+
+// 任务管理器（内存保存，进程重启后丢失）
+type JobManager struct {
+	mu   sync.RWMutex
+	jobs map[string]*Job
+}
+
+func NewJobManager() *JobManager {
+	return &JobManager{jobs: make(map[string]*Job)}
+}
+
+var Jobs = NewJobManager()
+
+func newJobID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+func (m *JobManager) Create() *Job {
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &Job{
+		id:        newJobID(),
+		phase:     JobSaving,
+		startedAt: time.Now(),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prune()
+	m.jobs[job.id] = job
+	return job
+}
+
+func (m *JobManager) Get(id string) (*Job, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	job, ok := m.jobs[id]
+	return job, ok
+}
+
+// 任务存在且尚未结束
+func (m *JobManager) Running(id string) bool {
+	job, ok := m.Get(id)
+	if !ok {
+		return false
+	}
+	job.mu.RLock()
+	defer job.mu.RUnlock()
+	return job.finishedAt.IsZero()
+}
+
+func (m *JobManager) List() []JobStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	statuses := make([]JobStatus, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		statuses = append(statuses, job.Status())
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].StartedAt.After(statuses[j].StartedAt)
+	})
+	return statuses
+}
+
+// 清理过期任务，调用方需持有写锁
+func (m *JobManager) prune() {
+	for id, job := range m.jobs {
+		job.mu.RLock()
+		expired := !job.finishedAt.IsZero() && time.Since(job.finishedAt) > jobRetention
+		job.mu.RUnlock()
+		if expired {
+			delete(m.jobs, id)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"gorm.io/gorm"
+
+	"awesomeProject1/backend/utils"
+)
+
+// 计算文件内容哈希
+func fileSHA256(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("文件打开失败: %v", err)
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, file)
+	if err != nil {
+		return "", 0, fmt.Errorf("文件读取失败: %v", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// 删除文件及其导入的全部数据
+func purgeLogFile(db *gorm.DB, f *utils.LogFile) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&utils.AttackLog{}, &utils.TcpLog{}, &utils.QuarantinedLine{}} {
+			if err := tx.Where("file_id = ?", f.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(f).Error
+	})
+}
+
+// 登记上传文件，记录为导入中状态，导入结束后由finishLogFile更新。
+// 案件内相同内容已导入完成且未要求替换时返回skip=true；未完成的导入视为未导入，清除残留数据后重新导入；
+// 要求替换时先删除案件内相同内容或同名文件导入的数据
+func registerLogFile(db *gorm.DB, path string, caseID uint, uploadID string, replace bool) (*utils.LogFile, bool, error) {
+	hash, size, err := fileSHA256(path)
+	if err != nil {
+		return nil, false, err
+	}
+	name := filepath.Base(path)
+
+	var existing utils.LogFile
+	err = db.Where("case_id = ? AND sha256 = ?", caseID, hash).First(&existing).Error
+	switch {
+	case err == nil && existing.Status == utils.LogFileComplete && !replace:
+		log.Printf("文件内容已导入，跳过: %s (文件ID:%d)", name, existing.ID)
+		return &existing, true, nil
+	case err == nil && existing.Status == utils.LogFilePending && Jobs.Running(existing.UploadID):
+		return nil, false, fmt.Errorf("相同内容的文件正在导入: %s (任务:%s)", existing.Name, existing.UploadID)
+	case err == nil && existing.Status != utils.LogFileComplete:
+		log.Printf("清除未完成的导入: %s (文件ID:%d 状态:%s)", existing.Name, existing.ID, existing.Status)
+		if err := purgeLogFile(db, &existing); err != nil {
+			return nil, false, fmt.Errorf("残留数据删除失败: %v", err)
+		}
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, false, fmt.Errorf("文件记录查询失败: %v", err)
+	}
+
+	if replace {
+		var previous []utils.LogFile
+		if err := db.Where("case_id = ? AND (sha256 = ? OR name = ?)", caseID, hash, name).Find(&previous).Error; err != nil {
+			return nil, false, fmt.Errorf("文件记录查询失败: %v", err)
+		}
+		for i := range previous {
+			log.Printf("替换已导入文件: %s (文件ID:%d)", previous[i].Name, previous[i].ID)
+			if err := purgeLogFile(db, &previous[i]); err != nil {
+				return nil, false, fmt.Errorf("旧数据删除失败: %v", err)
+			}
+		}
+	}
+
+	record := &utils.LogFile{CaseID: caseID, Name: name, SHA256: hash, Size: size, UploadID: uploadID, Status: utils.LogFilePending}
+	if err := db.Create(record).Error; err != nil {
+		return nil, false, fmt.Errorf("文件记录保存失败: %v", err)
+	}
+	return record, false, nil
+}
+
+// 导入结束：成功时回写统计并标记为完成；失败或取消时删除文件记录及已导入的部分数据，
+// 删除失败时标记为失败，再次上传相同内容时重新导入
+func finishLogFile(db *gorm.DB, f *utils.LogFile, summaries []IngestSummary, ingestErr error) {
+	if ingestErr != nil {
+		if err := purgeLogFile(db, f); err != nil {
+			log.Printf("未完成导入的数据删除失败 (文件ID:%d): %v", f.ID, err)
+			if err := db.Model(f).Update("status", utils.LogFileFailed).Error; err != nil {
+				log.Printf("文件状态更新失败: %v", err)
+			}
+			return
+		}
+		log.Printf("导入未完成，已删除文件数据: %s (文件ID:%d)", f.Name, f.ID)
+		return
+	}
+
+	for _, s := range summaries {
+		f.Lines += s.Lines
+		f.Accepted += s.Accepted
+		f.Rejected += s.Rejected
+		f.Duplicates += s.Duplicates
+	}
+	f.Status = utils.LogFileComplete
+	if err := db.Model(f).Updates(map[string]interface{}{
+		"lines":      f.Lines,
+		"accepted":   f.Accepted,
+		"rejected":   f.Rejected,
+		"duplicates": f.Duplicates,
+		"status":     f.Status,
+	}).Error; err != nil {
+		log.Printf("文件统计更新失败: %v", err)
+	}
+}
@@ -0,0 +1,13 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+func ListTcpProfilesHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   ListTcpProfiles(),
+	})
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"awesomeProject1/backend/utils"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// 查询案件内隔离的异常日志行，可按上传任务与文件过滤
+func QuarantineHandler(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+
+	caseID, err := utils.ResolveCase(utils.LogDB, c.Query("caseId"))
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := utils.LogDB.Model(&utils.QuarantinedLine{}).Where("case_id = ?", caseID)
+	if uploadID := c.Query("upload"); uploadID != "" {
+		query = query.Where("upload_id = ?", uploadID)
+	}
+	if file := c.Query("file"); file != "" {
+		query = query.Where("file = ?", file)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		log.Printf("查询失败: %v", err)
+		errorResponse(c, http.StatusInternalServerError, "数据获取失败")
+		return
+	}
+
+	var lines []utils.QuarantinedLine
+	if err := query.Order("id asc").Offset((page - 1) * limit).Limit(limit).Find(&lines).Error; err != nil {
+		log.Printf("查询失败: %v", err)
+		errorResponse(c, http.StatusInternalServerError, "数据获取失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"total": total,
+			"lines": lines,
+		},
+	})
+}
@@ -0,0 +1,296 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+
+	"awesomeProject1/backend/utils"
+)
+
+// TcpLog字段名（与数据库列名一致）及其表头别名
+var tcpFieldHeaders = map[string][]string{
+	"log_time":         {"SAVETIME"},
+	"start_time":       {"BEGINTIME", "STARTTIME"},
+	"end_time":         {"ENDTIME"},
+	"established_time": {"ESTABLISHTIME"},
+	"flow_status":      {"FLOWSTATUS"},
+	"duration":         {"SECONDS", "DURATION"},
+	"server_ip":        {"SERVERIP", "DSTIP"},
+	"server_port":      {"SERVERPORT", "DSTPORT"},
+	"client_ip":        {"CLIENTIP", "SRCIP"},
+	"client_port":      {"CLIENTPORT", "SRCPORT"},
+	"ttl_server":       {"TTLSERVER"},
+	"ttl_client":       {"TTLCLIENT"},
+	"protocol":         {"PROTOCOL"},
+	"client_plr":       {"CLIENTPLR"},
+	"server_plr":       {"SERVERPLR"},
+	"down_bps":         {"DOWNBPS"},
+	"up_bps":           {"UPBPS"},
+	"down_bytes":       {"DOWNBYTES"},
+	"up_bytes":         {"UPBYTES"},
+	"packets_sent":     {"PACKETSSENT", "SENDPKTS"},
+	"packets_receive":  {"PACKETSRECEIVE", "RECVPKTS"},
+	"custom_status":    {"CUSTOMSTATUS"},
+}
+
+var tcpTimeFields = map[string]bool{
+	"log_time":         true,
+	"start_time":       true,
+	"end_time":         true,
+	"established_time": true,
+}
+
+// 每个方案必须映射的字段
+var tcpRequiredFields = []string{"start_time", "server_ip", "server_port", "client_ip"}
+
+// TCP会话日志列映射方案，Columns为字段名到列索引（按空白分隔）的映射
+type TcpColumnProfile struct {
+	Name       string         `yaml:"name" json:"name"`
+	TimeFormat string         `yaml:"timeFormat" json:"timeFormat"` // 时间格式，含空格时占用多列
+	Columns    map[string]int `yaml:"columns" json:"columns"`
+
+	minFields  int
+	timeTokens int
+}
+
+func (p *TcpColumnProfile) compile() error {
+	if p.Name == "" {
+		return fmt.Errorf("列映射方案名称不能为空")
+	}
+	if p.TimeFormat == "" {
+		p.TimeFormat = attackTimeFormat
+	}
+	for _, field := range tcpRequiredFields {
+		if _, ok := p.Columns[field]; !ok {
+			return fmt.Errorf("列映射方案%s缺少必需字段: %s", p.Name, field)
+		}
+	}
+
+	p.timeTokens = strings.Count(p.TimeFormat, " ") + 1
+	p.minFields = 0
+	for field, idx := range p.Columns {
+		if _, known := tcpFieldHeaders[field]; !known {
+			return fmt.Errorf("列映射方案%s包含未知字段: %s", p.Name, field)
+		}
+		if idx < 0 {
+			return fmt.Errorf("列映射方案%s字段%s列索引无效: %d", p.Name, field, idx)
+		}
+		end := idx + 1
+		if tcpTimeFields[field] {
+			end = idx + p.timeTokens
+		}
+		if end > p.minFields {
+			p.minFields = end
+		}
+	}
+	return nil
+}
+
+// 内置方案，对应原始探针导出格式
+func defaultTcpProfile() *TcpColumnProfile {
+	p := &TcpColumnProfile{
+		Name:       "default",
+		TimeFormat: attackTimeFormat,
+		Columns: map[string]int{
+			"log_time":         tcpLogTimeCol,
+			"start_time":       tcpStartTimeCol,
+			"end_time":         tcpEndTimeCol,
+			"established_time": tcpConnectionTime,
+			"flow_status":      tcpFlowStatus,
+			"duration":         tcpDuration,
+			"server_ip":        tcpServerIP,
+			"server_port":      tcpServerPort,
+			"client_ip":        tcpClientIP,
+			"client_port":      tcpClientPort,
+			"ttl_server":       tcpTTLServer,
+			"ttl_client":       tcpTTLClient,
+			"protocol":         tcpProtocol,
+			"client_plr":       tcpClientPLR,
+			"server_plr":       tcpServerPLR,
+			"down_bps":         tcpDownBPS,
+			"up_bps":           tcpUpBPS,
+			"down_bytes":       tcpDownBytes,
+			"up_bytes":         tcpUpBytes,
+			"packets_sent":     tcpPacketsSent,
+			"packets_receive":  tcpPacketReceive,
+			"custom_status":    tcpCustomStatus,
+		},
+	}
+	if err := p.compile(); err != nil {
+		panic(err)
+	}
+	return p
+}
+
+var (
+	tcpProfileMu sync.RWMutex
+	tcpProfiles  = map[string]*TcpColumnProfile{"default": defaultTcpProfile()}
+)
+
+// 按名称获取列映射方案，名称为空时返回内置方案
+func GetTcpProfile(name string) (*TcpColumnProfile, error) {
+	if name == "" {
+		name = "default"
+	}
+	tcpProfileMu.RLock()
+	defer tcpProfileMu.RUnlock()
+	p, ok := tcpProfiles[name]
+	if !ok {
+		return nil, fmt.Errorf("列映射方案不存在: %s", name)
+	}
+	return p, nil
+}
+
+func ListTcpProfiles() []*TcpColumnProfile {
+	tcpProfileMu.RLock()
+	defer tcpProfileMu.RUnlock()
+	profiles := make([]*TcpColumnProfile, 0, len(tcpProfiles))
+	for _, p := range tcpProfiles {
+		profiles = append(profiles, p)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+	return profiles
+}
+
+// 加载列映射方案文件，文件不存在时仅使用内置方案
+func LoadTcpProfiles(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		log.Printf("列映射方案文件不存在，仅使用内置方案: %s", path)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("列映射方案文件读取失败: %v", err)
+	}
+
+	var file struct {
+		Profiles []*TcpColumnProfile `yaml:"profiles"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("列映射方案文件解析失败: %v", err)
+	}
+
+	loaded := map[string]*TcpColumnProfile{"default": defaultTcpProfile()}
+	for _, p := range file.Profiles {
+		if err := p.compile(); err != nil {
+			return err
+		}
+		if _, exists := loaded[p.Name]; exists {
+			return fmt.Errorf("列映射方案名称重复: %s", p.Name)
+		}
+		loaded[p.Name] = p
+	}
+
+	tcpProfileMu.Lock()
+	tcpProfiles = loaded
+	tcpProfileMu.Unlock()
+	log.Printf("列映射方案加载成功: %s (方案:%d)", path, len(loaded))
+	return nil
+}
+
+// 表头中与字段别名匹配的列不少于该数量时视为表头行
+const headerMinMatches = 3
+
+func headerField(token string) string {
+	upper := strings.ToUpper(strings.Trim(token, "#,;\""))
+	for field, aliases := range tcpFieldHeaders {
+		if upper == strings.ToUpper(field) || upper == strings.ReplaceAll(strings.ToUpper(field), "_", "") {
+			return field
+		}
+		for _, alias := range aliases {
+			if upper == alias {
+				return field
+			}
+		}
+	}
+	return ""
+}
+
+// 尝试将行识别为表头并生成对应方案，时间格式沿用base；非表头返回nil
+func profileFromHeader(line string, base *TcpColumnProfile) *TcpColumnProfile {
+	tokens := strings.Fields(line)
+	matched := 0
+	for _, t := range tokens {
+		if headerField(t) != "" {
+			matched++
+		}
+	}
+	if matched < headerMinMatches {
+		return nil
+	}
+
+	// 表头中时间列只占一个词，数据行中按时间格式可能占用多列
+	p := &TcpColumnProfile{
+		Name:       base.Name + "+header",
+		TimeFormat: base.TimeFormat,
+		Columns:    make(map[string]int),
+	}
+	extra := strings.Count(base.TimeFormat, " ")
+	offset := 0
+	for i, t := range tokens {
+		field := headerField(t)
+		if field != "" {
+			if _, dup := p.Columns[field]; !dup {
+				p.Columns[field] = i + offset
+			}
+		}
+		if tcpTimeFields[field] {
+			offset += extra
+		}
+	}
+	if err := p.compile(); err != nil {
+		log.Printf("表头识别失败，沿用方案%s: %v", base.Name, err)
+		return nil
+	}
+	return p
+}
+
+// 按方案解析TCP日志行，返回的错误即为隔离原因
+func (p *TcpColumnProfile) parse(line string) (utils.TcpLog, error) {
+	r := newFieldReader(line, p.Columns)
+	if len(r.parts) < p.minFields {
+		return utils.TcpLog{}, fmt.Errorf("TCP日志字段不足: %d < %d", len(r.parts), p.minFields)
+	}
+
+	logData := utils.TcpLog{
+		LogTime:         r.time("log_time", p.TimeFormat),
+		StartTime:       r.time("start_time", p.TimeFormat),
+		EndTime:         r.time("end_time", p.TimeFormat),
+		EstablishedTime: r.time("established_time", p.TimeFormat),
+		FlowStatus:      r.int("flow_status"),
+		Duration:        r.float("duration"),
+		ServerIP:        r.ip("server_ip"),
+		ServerPort:      r.port("server_port"),
+		ClientIP:        r.ip("client_ip"),
+		ClientPort:      r.port("client_port"),
+		TTLServer:       r.int("ttl_server"),
+		TTLClient:       r.int("ttl_client"),
+		Protocol:        r.int("protocol"),
+		ClientPLR:       r.float("client_plr"),
+		ServerPLR:       r.float("server_plr"),
+		DownBPS:         r.int64("down_bps"),
+		UpBPS:           r.int64("up_bps"),
+		DownBytes:       r.int64("down_bytes"),
+		UpBytes:         r.int64("up_bytes"),
+
+		PacketsSent:   r.int("packets_sent"),
+		PacketReceive: r.int("packets_receive"),
+		CustomStatus:  r.int("custom_status"),
+	}
+	if r.err != nil {
+		return utils.TcpLog{}, r.err
+	}
+	if !logData.EndTime.IsZero() && logData.EndTime.Before(logData.StartTime) {
+		return utils.TcpLog{}, fmt.Errorf("结束时间早于开始时间")
+	}
+	if logData.Duration < 0 || logData.UpBytes < 0 || logData.DownBytes < 0 {
+		return utils.TcpLog{}, fmt.Errorf("时长或字节数为负")
+	}
+	return logData, nil
+}
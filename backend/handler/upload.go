@@ -1,32 +1,29 @@
 package handler
 
 import (
-	"bufio"
 	"fmt"
 	"log"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"sync"
-	"time"
 
 	"github.com/gin-gonic/gin"
 
 	"awesomeProject1/backend/analyzePipe"
+	"awesomeProject1/backend/migrate"
 	"awesomeProject1/backend/utils"
 )
 
 const (
-	attackLogMinFields = 3 // 根据实际字段数调整
+	attackLogMinFields = 9 // 攻击日志完整列数
 	attackTimeColumn   = 1 // 日期字段位置
 	attackTimeFormat   = "2006-01-02 15:04:05"
 )
 
 const (
-	tcpLogMinFields   = 30
 	tcpLogTimeCol     = 1
 	tcpStartTimeCol   = 3
 	tcpEndTimeCol     = 5
@@ -46,278 +43,248 @@ const (
 	tcpUpBPS          = 30 // 上行吞吐
 	tcpDownBytes      = 33
 	tcpUpBytes        = 34
+	tcpPacketsSent    = 31
+	tcpPacketReceive  = 32
+	tcpCustomStatus   = 37
 )
 
-var (
-	RawAttackData []utils.AttackLog
-	RawTcpData    []utils.TcpLog
-)
+// 攻击日志列映射
+var attackColumns = map[string]int{
+	"log_time":   attackTimeColumn, // 日期+时间占两列
+	"event_type": 3,                // 事件类型
+	"source_ip":  4,                // 源IP
+	"protocol":   5,                // 协议
+	"action":     6,                // 行为
+	"dest_ip":    7,                // 目标IP
+	"severity":   8,                // 严重性
+}
 
-func ParseAndSaveLogFile(fileName string, fileType string) {
-	file, err := os.Open(fileName)
-	if err != nil {
-		log.Printf("文件打开失败: %v", err)
-		return
+// 解析攻击日志，返回的错误即为隔离原因
+func parseAttackLine(line string) (utils.AttackLog, error) {
+	r := newFieldReader(line, attackColumns)
+	if len(r.parts) < attackLogMinFields {
+		return utils.AttackLog{}, fmt.Errorf("攻击日志字段不足: %d < %d", len(r.parts), attackLogMinFields)
 	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	lineCount := 0
 
-	for scanner.Scan() {
-		lineCount++
-		line := scanner.Text()
-		switch fileType {
-		case "attack":
-			if logData, ok := parseAttackLine(line); ok {
-				if err := utils.LogDB.Create(&logData).Error; err != nil {
-					log.Printf("攻击日志保存失败 行%d: %v", lineCount, err)
-				}
-				RawAttackData = append(RawAttackData, logData)
-			}
-		case "tcp":
-			if logData, ok := parseTcpLine(line); ok {
-				if err := utils.LogDB.Create(&logData).Error; err != nil {
-					log.Printf("TCP日志保存失败 行%d: %v", lineCount, err)
-				}
-				RawTcpData = append(RawTcpData, logData)
-			}
-		}
+	logData := utils.AttackLog{
+		LogTime:   r.time("log_time", attackTimeFormat),
+		EventType: r.int("event_type"),
+		SourceIP:  r.ip("source_ip"),
+		Protocol:  r.int("protocol"),
+		Action:    r.str("action"),
+		DestIP:    r.ip("dest_ip"),
+		Severity:  r.int("severity"),
 	}
-
-	if err := scanner.Err(); err != nil {
-		log.Printf("文件读取错误: %v", err)
+	if r.err != nil {
+		return utils.AttackLog{}, r.err
 	}
+	return logData, nil
 }
 
-// 解析攻击日志
-func parseAttackLine(line string) (utils.AttackLog, bool) {
-	parts := strings.Fields(line)
-	if parts[0] == "" {
-		return utils.AttackLog{}, false
-	}
-	if len(parts) < attackLogMinFields {
-		log.Printf("攻击日志字段不足: %s", line)
-		return utils.AttackLog{}, false
-	}
+// 分析使用的存储，由main注入
+var AnalysisStores analyzePipe.Stores
 
-	// 合并日期和时间字段
-	timeStr := parts[attackTimeColumn] + " " + parts[attackTimeColumn+1]
-	logTime, err := time.Parse(attackTimeFormat, timeStr)
-	if err != nil {
-		log.Printf("时间解析失败: %v | 行内容: %s", err, line)
-		return utils.AttackLog{}, false
-	}
+// 上传文件保存目录，按案件分子目录
+var UploadDir = "uploads"
 
-	return utils.AttackLog{
-		LogTime:   logTime,
-		EventType: safeAtoi(parts[3]), // 事件类型
-		SourceIP:  parts[4],           // 源IP
-		Protocol:  safeAtoi(parts[5]), // 协议
-		Action:    parts[6],           // 行为
-		DestIP:    parts[7],           // 目标IP
-		Severity:  safeAtoi(parts[8]), // 严重性
-	}, true
+// 已保存待解析的上传文件
+type uploadedFile struct {
+	path    string
+	logType string
 }
 
-// 解析TCP日志
-func parseTcpLine(line string) (utils.TcpLog, bool) {
-	parts := strings.Fields(line)
-	if len(parts) < tcpLogMinFields {
-		log.Printf("TCP日志字段不足: %s", line)
-		return utils.TcpLog{}, false
+func UploadHandler(c *gin.Context) {
+	utils.AttackFiles = sync.Map{}
+	utils.TcpFiles = sync.Map{}
+
+	if err := c.Request.ParseMultipartForm(32 << 20); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "表单解析失败"})
+		return
 	}
 
-	// 解析时间字段
-	logTimeStr := parts[tcpLogTimeCol] + " " + parts[tcpLogTimeCol+1]
-	logTime, err := time.Parse(attackTimeFormat, logTimeStr)
-	if err != nil {
-		log.Printf("TCP日志记录时间解析失败: %v | 行内容: %s", err, line)
-		return utils.TcpLog{}, false
+	// attack/tcp字段可为纯文本或压缩包，archive字段为混合压缩包（按内容识别类型）
+	attackFiles := c.Request.MultipartForm.File["attack"]
+	tcpFiles := c.Request.MultipartForm.File["tcp"]
+	archiveFiles := c.Request.MultipartForm.File["archive"]
+	if len(archiveFiles) == 0 {
+		if len(attackFiles) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "缺少攻击日志文件"})
+			return
+		}
+		if len(tcpFiles) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "缺少TCP日志文件"})
+			return
+		}
+	}
+	for _, fileHeader := range archiveFiles {
+		if archiveKind(fileHeader.Filename) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "不支持的压缩格式: " + fileHeader.Filename})
+			return
+		}
 	}
 
-	startTimeStr := parts[tcpStartTimeCol] + " " + parts[tcpStartTimeCol+1]
-	startTime, err := time.Parse(attackTimeFormat, startTimeStr)
+	caseID, err := utils.ResolveCase(utils.LogDB, c.PostForm("caseId"))
 	if err != nil {
-		log.Printf("TCP日志开始时间解析失败: %v | 行内容: %s", err, line)
-		return utils.TcpLog{}, false
+		errorResponse(c, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	endTimeStr := parts[tcpEndTimeCol] + " " + parts[tcpEndTimeCol+1]
-	endTime, err := time.Parse(attackTimeFormat, endTimeStr)
+	tcpProfile := c.PostForm("tcpProfile")
+	if _, err := GetTcpProfile(tcpProfile); err != nil {
+		errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	window, err := analyzePipe.ParseWindow(c.PostForm("start"), c.PostForm("end"))
 	if err != nil {
-		log.Printf("TCP日志结束时间解析失败: %v | 行内容: %s", err, line)
-		return utils.TcpLog{}, false
+		errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	// 规则名须在导入前校验：导入成功后重复上传会被跳过，分析阶段才报错将无法重新分析这批数据
+	enabled, disabled := splitFormList(c.PostForm("enableDetectors")), splitFormList(c.PostForm("disableDetectors"))
+	if err := checkDetectorNames(enabled, disabled); err != nil {
+		errorResponse(c, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	establishedTimeStr := parts[tcpConnectionTime] + " " + parts[tcpConnectionTime+1]
-	establishedTime, err := time.Parse(attackTimeFormat, establishedTimeStr)
-	if err != nil {
-		log.Printf("TCP连接时间解析失败: %v | 行内容: %s", err, line)
-		return utils.TcpLog{}, false
+	// 上传的临时文件在请求结束后即被清理，需在返回前落盘；
+	// 按任务分目录保存，避免同一案件并发上传的同名文件在解析期间被覆盖，任务结束后删除
+	job := Jobs.Create()
+	uploadDir := filepath.Join(UploadDir, fmt.Sprintf("case-%d", caseID), job.ID())
+	var saved []uploadedFile
+	saveFiles := func(files []*multipart.FileHeader, logType string) error {
+		if err := os.MkdirAll(uploadDir, 0755); err != nil {
+			return fmt.Errorf("创建目录失败: %v", err)
+		}
+		for _, fileHeader := range files {
+			filePath := filepath.Join(uploadDir, filepath.Base(fileHeader.Filename))
+			if err := c.SaveUploadedFile(fileHeader, filePath); err != nil {
+				return fmt.Errorf("文件保存失败: %v", err)
+			}
+			saved = append(saved, uploadedFile{path: filePath, logType: logType})
+		}
+		return nil
+	}
+	for _, err := range []error{
+		saveFiles(attackFiles, "attack"),
+		saveFiles(tcpFiles, "tcp"),
+		saveFiles(archiveFiles, "archive"),
+	} {
+		if err != nil {
+			job.AddError(err)
+		}
+	}
+	if status := job.Status(); len(status.Errors) > 0 {
+		removeUploadDir(uploadDir)
+		job.Finish()
+		c.JSON(http.StatusInternalServerError, gin.H{"errors": status.Errors, "jobId": job.ID()})
+		return
 	}
 
-	// 解析其他字段
-	return utils.TcpLog{
-		LogTime:         logTime,
-		StartTime:       startTime,
-		EndTime:         endTime,
-		EstablishedTime: establishedTime,
-		FlowStatus:      safeAtoi(parts[tcpFlowStatus]),
-		Duration:        safeAtof(parts[tcpDuration]),
-		ServerIP:        parts[tcpServerIP],
-		ServerPort:      safeAtoi(parts[tcpServerPort]),
-		ClientIP:        parts[tcpClientIP],
-		ClientPort:      safeAtoi(parts[tcpClientPort]),
-		TTLServer:       safeAtoi(parts[tcpTTLServer]),
-		TTLClient:       safeAtoi(parts[tcpTTLClient]),
-		Protocol:        safeAtoi(parts[tcpProtocol]),
-		ClientPLR:       safeAtof(parts[tcpClientPLR]),
-		ServerPLR:       safeAtof(parts[tcpServerPLR]),
-		DownBPS:         safeAtoi64(parts[tcpDownBPS]),
-		UpBPS:           safeAtoi64(parts[tcpUpBPS]),
-		DownBytes:       safeAtoi64(parts[tcpDownBytes]),
-		UpBytes:         safeAtoi64(parts[tcpUpBytes]),
+	ingestOpts := IngestOptions{
+		UploadID:   job.ID(),
+		CaseID:     caseID,
+		TcpProfile: tcpProfile,
+		Replace:    c.PostForm("replace") == "true",
+		OnProgress: job.AddLines,
+	}
+	opts := analyzePipe.PipelineOptions{
+		CaseID:            caseID,
+		Window:            window,
+		Incremental:       true, // 仅分析本次及此前未分析的新数据
+		EnabledDetectors:  enabled,
+		DisabledDetectors: disabled,
+	}
+	go runUploadJob(job, uploadDir, saved, ingestOpts, opts)
 
-		PacketsSent:   safeAtoi(parts[31]),
-		PacketReceive: safeAtoi(parts[32]),
-		CustomStatus:  safeAtoi(parts[37]),
-	}, true
+	c.JSON(http.StatusAccepted, gin.H{
+		"status": "success",
+		"data":   gin.H{"jobId": job.ID(), "caseId": caseID},
+	})
 }
 
-func UploadHandler(c *gin.Context) {
-	utils.AttackFiles = sync.Map{}
-	utils.TcpFiles = sync.Map{}
-
-	if err := c.Request.ParseMultipartForm(32 << 20); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "表单解析失败"})
-		return
-	}
+// 后台执行解析与分析
+func runUploadJob(job *Job, uploadDir string, files []uploadedFile, ingestOpts IngestOptions, opts analyzePipe.PipelineOptions) {
+	defer job.Finish()
+	ctx := job.Context()
 
+	job.SetPhase(JobParsing)
 	var wg sync.WaitGroup
-	errorChan := make(chan error, 2)
-
-	processFiles := func(files []*multipart.FileHeader, logType string) {
+	processFiles := func(logType string) {
+		defer wg.Done()
 		defer func() {
 			if r := recover(); r != nil {
-				errorChan <- fmt.Errorf("处理异常: %v", r)
+				job.AddError(fmt.Errorf("处理异常: %v", r))
 			}
 		}()
 
-		for _, fileHeader := range files {
-			uploadDir := "uploads"
-			if err := os.MkdirAll(uploadDir, 0755); err != nil {
-				errorChan <- fmt.Errorf("创建目录失败: %v", err)
-				return
+		for _, f := range files {
+			if f.logType != logType {
+				continue
 			}
-
-			filePath := filepath.Join(uploadDir, fileHeader.Filename)
-			if err := c.SaveUploadedFile(fileHeader, filePath); err != nil {
-				errorChan <- fmt.Errorf("文件保存失败: %v", err)
+			log.Printf("正在处理文件: %s (任务:%s)", filepath.Base(f.path), job.ID())
+			summaries, err := IngestPath(ctx, f.path, f.logType, ingestOpts)
+			for _, summary := range summaries {
+				job.AddFile(summary)
+			}
+			if err != nil {
+				if ctx.Err() == nil {
+					job.AddError(err)
+				}
 				return
 			}
-
-			// 打印调试信息
-			log.Printf("正在处理文件: %s", filePath)
-			ParseAndSaveLogFile(filePath, logType)
 		}
 	}
 
-	if attackFiles, ok := c.Request.MultipartForm.File["attack"]; ok {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			processFiles(attackFiles, "attack")
-		}()
-	} else {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "缺少攻击日志文件"})
-		return
-	}
-
-	if tcpFiles, ok := c.Request.MultipartForm.File["tcp"]; ok {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			processFiles(tcpFiles, "tcp")
-		}()
-	} else {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "缺少TCP日志文件"})
-		return
+	wg.Add(3)
+	go processFiles("attack")
+	go processFiles("tcp")
+	go processFiles("archive")
+	wg.Wait()
+	// 数据已入库（或导入取消、失败），上传文件不再需要
+	removeUploadDir(uploadDir)
+
+	// 新导入的会话可能超出现有分区范围
+	if err := migrate.ExtendTcpPartitions(utils.LogDB); err != nil {
+		log.Printf("%v", err)
 	}
 
-	go func() {
-		wg.Wait()
-		close(errorChan)
-	}()
-
-	var errors []string
-	for err := range errorChan {
-		errors = append(errors, err.Error())
-	}
-	if len(errors) > 0 {
-		c.JSON(http.StatusInternalServerError, gin.H{"errors": errors})
+	status := job.Status()
+	if ctx.Err() != nil || len(status.Errors) > 0 {
 		return
 	}
-	if !analyzePipe.AnalyzePipeline() {
-		c.JSON(http.StatusInternalServerError, gin.H{"errors": errors})
+	if allSkipped(status.Files) {
+		log.Printf("上传文件均已导入过，跳过分析 (任务:%s)", job.ID())
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"status": "success"})
-}
-
-// 增强类型转换函数
-func safeAtoi(s string) int {
-	if s == "" {
-		return 0
-	}
 
-	cleanStr := strings.ReplaceAll(s, ":", "")
-
-	v, err := strconv.Atoi(cleanStr)
-	if err != nil {
-		log.Printf("整数转换失败: %s (原始值)", s)
-		return 0
+	opts.OnStage = job.SetPhase
+	if err := analyzePipe.AnalyzePipeline(ctx, AnalysisStores, opts); err != nil && ctx.Err() == nil {
+		job.AddError(err)
 	}
-	return v
 }
 
-func safeAtoi64(s string) int64 {
-	if s == "" {
-		return 0
+func removeUploadDir(dir string) {
+	if err := os.RemoveAll(dir); err != nil {
+		log.Printf("上传文件清理失败: %v", err)
 	}
+}
 
-	// 处理特殊字符（冒号、逗号等）
-	cleanStr := strings.ReplaceAll(s, ":", "")       // 移除时间分隔符
-	cleanStr = strings.ReplaceAll(cleanStr, ",", "") // 移除数字千分位分隔符
-
-	// 使用 ParseInt 替代 Atoi 实现更精确的控制
-	v, err := strconv.ParseInt(cleanStr, 10, 64) // 10进制，64位整数[2](@ref)
-	if err != nil {
-		if numError, ok := err.(*strconv.NumError); ok {
-			// 详细错误分类处理
-			switch numError.Err {
-			case strconv.ErrRange:
-				log.Printf("数值超出int64范围: %s (原始值 %s)", numError.Err, s)
-			case strconv.ErrSyntax:
-				log.Printf("非法数字格式: %s (原始值 %s)", numError.Err, s)
-			}
-		} else {
-			log.Printf("未知转换错误: %s (原始值 %s)", err, s)
+func allSkipped(files []IngestSummary) bool {
+	for _, f := range files {
+		if !f.Skipped {
+			return false
 		}
-		return 0
 	}
-	return v
+	return len(files) > 0
 }
 
-func safeAtof(s string) float64 {
-	if s == "" {
-		return 0.0
-	}
-
-	v, err := strconv.ParseFloat(s, 64)
-	if err != nil {
-		log.Printf("浮点数转换失败: %s (原始值)", s)
-		return 0.0
+// 解析逗号分隔的表单参数
+func splitFormList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
-	return v
+	return items
 }
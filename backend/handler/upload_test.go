@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestUploadHandlerRejectsBeforeCreatingJob(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	defer func(old string) { UploadDir = old }(UploadDir)
+	UploadDir = dir
+
+	tests := []struct {
+		name   string
+		fields map[string]string
+	}{
+		{"未知启用规则", map[string]string{"enableDetectors": "port_scan, nope"}},
+		{"未知禁用规则", map[string]string{"disableDetectors": "nope"}},
+		{"未知TCP列映射", map[string]string{"tcpProfile": "nope"}},
+		{"时间窗口无效", map[string]string{"start": "yesterday"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			for _, field := range []string{"attack", "tcp"} {
+				w, _ := mw.CreateFormFile(field, field+".log")
+				w.Write([]byte("x\n"))
+			}
+			for k, v := range tt.fields {
+				mw.WriteField(k, v)
+			}
+			mw.Close()
+
+			jobs := len(Jobs.List())
+			req := httptest.NewRequest(http.MethodPost, "/upload", &body)
+			req.Header.Set("Content-Type", mw.FormDataContentType())
+			rec := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(rec)
+			c.Request = req
+			UploadHandler(c)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("状态码 = %d, want 400: %s", rec.Code, rec.Body)
+			}
+			if n := len(Jobs.List()); n != jobs {
+				t.Errorf("任务数 = %d, want %d（校验失败不应创建任务）", n, jobs)
+			}
+			if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+				t.Errorf("校验失败后仍保存了上传文件: %v", entries)
+			}
+		})
+	}
+}
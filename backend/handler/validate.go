@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxQuarantineContent = 4096 // 隔离行内容最大保存长度
+	qualityWarnThreshold = 0.9  // 质量分低于该值视为数据集不可靠
+)
+
+// 按列映射读取并校验字段，记录遇到的第一个错误
+type fieldReader struct {
+	parts   []string
+	columns map[string]int
+	err     error
+}
+
+func newFieldReader(line string, columns map[string]int) *fieldReader {
+	return &fieldReader{parts: strings.Fields(line), columns: columns}
+}
+
+func (r *fieldReader) fail(format string, args ...interface{}) {
+	if r.err == nil {
+		r.err = fmt.Errorf(format, args...)
+	}
+}
+
+// 获取字段原始值，未映射的字段返回false
+func (r *fieldReader) raw(field string) (string, bool) {
+	idx, ok := r.columns[field]
+	if !ok || r.err != nil {
+		return "", false
+	}
+	if idx >= len(r.parts) {
+		r.fail("字段%s缺失(第%d列)", field, idx+1)
+		return "", false
+	}
+	return r.parts[idx], true
+}
+
+func (r *fieldReader) str(field string) string {
+	v, _ := r.raw(field)
+	return v
+}
+
+func (r *fieldReader) int(field string) int {
+	s, ok := r.raw(field)
+	if !ok {
+		return 0
+	}
+	v, err := strconv.Atoi(strings.ReplaceAll(s, ":", ""))
+	if err != nil {
+		r.fail("字段%s不是整数: %q", field, s)
+	}
+	return v
+}
+
+func (r *fieldReader) int64(field string) int64 {
+	s, ok := r.raw(field)
+	if !ok {
+		return 0
+	}
+	// 处理特殊字符（冒号、逗号等）
+	clean := strings.ReplaceAll(strings.ReplaceAll(s, ":", ""), ",", "")
+	v, err := strconv.ParseInt(clean, 10, 64)
+	if err != nil {
+		if numError, ok := err.(*strconv.NumError); ok && numError.Err == strconv.ErrRange {
+			r.fail("字段%s超出int64范围: %q", field, s)
+		} else {
+			r.fail("字段%s不是整数: %q", field, s)
+		}
+	}
+	return v
+}
+
+func (r *fieldReader) float(field string) float64 {
+	s, ok := r.raw(field)
+	if !ok {
+		return 0
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		r.fail("字段%s不是数字: %q", field, s)
+	}
+	return v
+}
+
+func (r *fieldReader) ip(field string) string {
+	s, ok := r.raw(field)
+	if !ok {
+		return ""
+	}
+	if net.ParseIP(s) == nil {
+		r.fail("字段%s不是有效IP: %q", field, s)
+	}
+	return s
+}
+
+func (r *fieldReader) port(field string) int {
+	v := r.int(field)
+	if r.err == nil && (v < 0 || v > 65535) {
+		r.fail("字段%s端口超出范围: %d", field, v)
+	}
+	return v
+}
+
+// 读取时间字段，格式含空格时占用连续多列
+func (r *fieldReader) time(field, format string) time.Time {
+	idx, ok := r.columns[field]
+	if !ok || r.err != nil {
+		return time.Time{}
+	}
+	tokens := strings.Count(format, " ") + 1
+	if idx+tokens > len(r.parts) {
+		r.fail("字段%s缺失(第%d列)", field, idx+1)
+		return time.Time{}
+	}
+	value := strings.Join(r.parts[idx:idx+tokens], " ")
+	t, err := time.Parse(format, value)
+	if err != nil {
+		r.fail("字段%s时间格式错误: %q", field, value)
+	}
+	return t
+}
+
+// 质量分：成功解析的行占有效行（不含重复行）的比例
+func qualityScore(accepted, rejected int) float64 {
+	total := accepted + rejected
+	if total == 0 {
+		return 1
+	}
+	return float64(accepted) / float64(total)
+}
+
+func truncateContent(line string) string {
+	if len(line) > maxQuarantineContent {
+		line = line[:maxQuarantineContent]
+	}
+	return strings.ToValidUTF8(line, "?")
+}
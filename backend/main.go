@@ -1,34 +1,57 @@
 package main
 
 import (
+	"awesomeProject1/backend/bootstrap"
+	"awesomeProject1/backend/config"
 	"awesomeProject1/backend/routes"
-	"awesomeProject1/backend/utils"
+	"flag"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"log"
+	"os"
 	"path/filepath"
-	"runtime"
 )
 
-func getFrontendPath() string {
-	_, currentFile, _, _ := runtime.Caller(0)          // 获取当前文件路径
-	backendDir := filepath.Dir(currentFile)            // backend目录
-	return filepath.Join(backendDir, "..", "frontend") // 上溯到父目录再进frontend
-}
+func main() {
+	loader := config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	cfg, err := loader.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// 子命令：migrate、config
+	if args := flag.Args(); len(args) > 0 {
+		switch args[0] {
+		case "migrate":
+			if err = bootstrap.OpenDatabase(cfg); err == nil {
+				err = bootstrap.RunMigrate(args[1:])
+			}
+		case "config":
+			err = bootstrap.RunConfig(cfg, args[1:])
+		default:
+			log.Fatalf("未知命令: %s", args[0])
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	frontendPath := cfg.Server.FrontendDir
+	if _, err := os.Stat(filepath.Join(frontendPath, "index.html")); err != nil {
+		log.Fatalf("前端目录无效 %s: %v", frontendPath, err)
+	}
 
-func init() {
-	utils.InitDatabase()
-	if err := utils.InitNeo4j(
-		"bolt://localhost:7687",
-		"neo4j",
-		"yourPassword"); err != nil {
+	if err := bootstrap.LoadDetection(cfg); err != nil {
+		log.Fatal(err)
+	}
+	bootstrap.WatchConfigReload()
+	if _, err := bootstrap.OpenStores(cfg); err != nil {
 		log.Fatal(err)
 	}
-}
 
-func main() {
 	router := routes.SetupRouter()
-	frontendPath := getFrontendPath()
 
 	// CORS配置
 	router.Use(cors.Default())
@@ -42,5 +65,8 @@ func main() {
 	})
 
 	// API路由（来自routes包）
-	router.Run(":8080")
+	log.Printf("服务监听 %s", cfg.Server.Listen)
+	if err := router.Run(cfg.Server.Listen); err != nil {
+		log.Fatal(err)
+	}
 }
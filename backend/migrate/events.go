@@ -0,0 +1,264 @@
+package migrate
+
+import (
+	"awesomeProject1/backend/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"time"
+)
+
+const (
+	eventTable            = "apt_events"
+	eventFingerprintIndex = "idx_apt_events_case_fingerprint"
+	rekeyBatchSize        = 1000
+)
+
+// 重算指纹所需的事件列；触发次数列在v6之前、检测规则列在v10之前不存在
+type eventRow struct {
+	ID            uint
+	CaseID        uint
+	Fingerprint   string
+	Occurrences   int
+	Detector      string
+	StartTime     time.Time
+	EndTime       time.Time
+	SourceIP      string
+	DestIP        string
+	DestPort      int
+	EventName     string
+	EventType     string
+	SeverityLevel int
+}
+
+func (r eventRow) fingerprint() string {
+	e := utils.APTEvent{Detector: r.Detector, EventName: r.EventName, EventType: r.EventType, SourceIP: r.SourceIP, DestIP: r.DestIP,
+		DestPort: r.DestPort, StartTime: r.StartTime}
+	return e.ComputeFingerprint()
+}
+
+// 按当前指纹算法重算全部事件的指纹，同一案件内指纹相同的事件合并到最早的一条：
+// 扩展起止时间、取较高严重等级，存在触发次数列与证据表时累加触发次数并转移证据
+func rekeyEvents(tx *gorm.DB) error {
+	withOccurrences := tx.Migrator().HasColumn(&aptEventV6{}, "Occurrences")
+	columns := []string{"id", "case_id", "fingerprint", "start_time", "end_time", "source_ip", "dest_ip",
+		"dest_port", "event_name", "event_type", "severity_level"}
+	if withOccurrences {
+		columns = append(columns, "occurrences")
+	}
+	if tx.Migrator().HasColumn(&aptEventV10{}, "Detector") {
+		columns = append(columns, "detector")
+	}
+
+	type keptEvent struct {
+		event eventRow
+		dirty bool
+	}
+	kept := make(map[uint]map[string]*keptEvent)
+	var order []*keptEvent
+	merged := make(map[uint]uint) // 被合并事件ID -> 保留事件ID
+
+	var batch []eventRow
+	err := tx.Table(eventTable).Select(columns).Order("id").FindInBatches(&batch, rekeyBatchSize, func(_ *gorm.DB, _ int) error {
+		for _, e := range batch {
+			fp := e.fingerprint()
+			e.Occurrences = max(e.Occurrences, 1)
+			if kept[e.CaseID] == nil {
+				kept[e.CaseID] = make(map[string]*keptEvent)
+			}
+			k := kept[e.CaseID][fp]
+			if k == nil {
+				k = &keptEvent{event: e, dirty: e.Fingerprint != fp}
+				k.event.Fingerprint = fp
+				kept[e.CaseID][fp] = k
+				order = append(order, k)
+				continue
+			}
+			if e.StartTime.Before(k.event.StartTime) {
+				k.event.StartTime = e.StartTime
+			}
+			if e.EndTime.After(k.event.EndTime) {
+				k.event.EndTime = e.EndTime
+			}
+			k.event.SeverityLevel = max(k.event.SeverityLevel, e.SeverityLevel)
+			k.event.Occurrences += e.Occurrences
+			k.dirty = true
+			merged[e.ID] = k.event.ID
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	if err := moveEvidence(tx, merged); err != nil {
+		return err
+	}
+	var duplicates []uint
+	for id := range merged {
+		duplicates = append(duplicates, id)
+	}
+	for start := 0; start < len(duplicates); start += rekeyBatchSize {
+		end := min(start+rekeyBatchSize, len(duplicates))
+		if err := tx.Exec("DELETE FROM "+eventTable+" WHERE id IN ?", duplicates[start:end]).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, k := range order {
+		if !k.dirty {
+			continue
+		}
+		updates := map[string]interface{}{
+			"fingerprint":    k.event.Fingerprint,
+			"start_time":     k.event.StartTime,
+			"end_time":       k.event.EndTime,
+			"severity_level": k.event.SeverityLevel,
+		}
+		if withOccurrences {
+			updates["occurrences"] = k.event.Occurrences
+		}
+		if err := tx.Table(eventTable).Where("id = ?", k.event.ID).UpdateColumns(updates).Error; err != nil {
+			return err
+		}
+	}
+	if len(merged) > 0 {
+		log.Printf("合并重复事件%d条", len(merged))
+	}
+	return nil
+}
+
+// 将被合并事件的证据转移到保留的事件
+func moveEvidence(tx *gorm.DB, merged map[uint]uint) error {
+	if len(merged) == 0 || !tx.Migrator().HasTable(&eventEvidenceV6{}) {
+		return nil
+	}
+	for from, to := range merged {
+		var rows []eventEvidenceV6
+		if err := tx.Where("event_id = ?", from).Find(&rows).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			continue
+		}
+		for i := range rows {
+			rows[i].ID = 0
+			rows[i].EventID = to
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("event_id = ?", from).Delete(&eventEvidenceV6{}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 事件指纹列与案件内唯一索引
+func addEventFingerprints(tx *gorm.DB) error {
+	if err := addColumns(tx, &aptEventV5{}, "Fingerprint"); err != nil {
+		return err
+	}
+	if err := tx.AutoMigrate(&analysisWatermarkV5{}); err != nil {
+		return err
+	}
+	if err := rekeyEvents(tx); err != nil {
+		return err
+	}
+	if tx.Migrator().HasIndex(eventTable, eventFingerprintIndex) {
+		return nil
+	}
+	return tx.Exec("CREATE UNIQUE INDEX " + eventFingerprintIndex + " ON " + eventTable + " (case_id, fingerprint)").Error
+}
+
+func dropEventFingerprints(tx *gorm.DB) error {
+	if tx.Migrator().HasIndex(eventTable, eventFingerprintIndex) {
+		if err := tx.Migrator().DropIndex(eventTable, eventFingerprintIndex); err != nil {
+			return err
+		}
+	}
+	if err := tx.Migrator().DropTable(&analysisWatermarkV5{}); err != nil {
+		return err
+	}
+	return dropColumns(tx, &aptEventV5{}, "Fingerprint")
+}
+
+// 事件证据表与触发次数，按分桶指纹合并已有事件
+func addEventEvidence(tx *gorm.DB) error {
+	if err := addColumns(tx, &aptEventV6{}, "Occurrences"); err != nil {
+		return err
+	}
+	if err := tx.AutoMigrate(&eventEvidenceV6{}); err != nil {
+		return err
+	}
+	return rekeyEvents(tx)
+}
+
+func dropEventEvidence(tx *gorm.DB) error {
+	if err := tx.Migrator().DropTable(&eventEvidenceV6{}); err != nil {
+		return err
+	}
+	return dropColumns(tx, &aptEventV6{}, "Occurrences")
+}
+
+// 事件结构化信息列
+func addEventMetadata(tx *gorm.DB) error {
+	return addColumns(tx, &aptEventV7{}, "Metadata")
+}
+
+func dropEventMetadata(tx *gorm.DB) error {
+	return dropColumns(tx, &aptEventV7{}, "Metadata")
+}
+
+// v10时内置规则输出的事件名称，用于回填已有事件的检测规则；肉鸡规则的事件类型带ZOMBIE_前缀。
+// 自定义规则的事件无法确定来源，检测规则保持为空
+var builtinEventDetectors = []struct {
+	eventName string
+	zombie    bool
+	detector  string
+}{
+	{"BruteForce", false, "connection_frequency"},
+	{"NewConnection", false, "new_ip_connections"},
+	{"PortScan", false, "port_scan"},
+	{"ProtoAnomaly", false, "protocol_anomaly"},
+	{"ReverseConnection", false, "victim_outbound"},
+	{"DataTransfer", false, "data_exfiltration"},
+	{"MaliciousConnection", false, "malicious_connection"},
+	{"LongConnection", false, "c2_long_connection"},
+	{"C2Communication", false, "beaconing"},
+	{"LateralMovement", false, "lateral_movement"},
+	{"PivotChain", false, "pivot_chain"},
+	{"TrojanImplant", false, "trojan_implant"},
+	{"HiddenAttack", false, "hidden_attack"},
+	{"NewConnection", true, "zombie_new_connections"},
+	{"ZOMBIE_ReverseConnection", true, "zombie_reverse_connection"},
+	{"ZOMBIE_ACTIVITY_SPIKE", true, "zombie_activity_spike"},
+	{"ZOMBIE_MALICIOUS_CONN", true, "zombie_malicious_connection"},
+}
+
+// 事件检测规则列：回填内置规则的事件后按含规则的指纹重算
+func addEventDetector(tx *gorm.DB) error {
+	if err := addColumns(tx, &aptEventV10{}, "Detector"); err != nil {
+		return err
+	}
+	for _, b := range builtinEventDetectors {
+		query := tx.Table(eventTable).Where("(detector = '' OR detector IS NULL) AND event_name = ?", b.eventName)
+		if b.zombie {
+			query = query.Where("event_type LIKE ?", "ZOMBIE%")
+		} else {
+			query = query.Where("event_type NOT LIKE ?", "ZOMBIE%")
+		}
+		if err := query.UpdateColumn("detector", b.detector).Error; err != nil {
+			return err
+		}
+	}
+	return rekeyEvents(tx)
+}
+
+func dropEventDetector(tx *gorm.DB) error {
+	if err := dropColumns(tx, &aptEventV10{}, "Detector"); err != nil {
+		return err
+	}
+	return rekeyEvents(tx)
+}
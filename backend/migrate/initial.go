@@ -0,0 +1,114 @@
+package migrate
+
+import (
+	"awesomeProject1/backend/utils"
+	"gorm.io/gorm"
+	"log"
+)
+
+// 行自然键唯一索引，名称与模型默认索引名一致（分区迁移按此名称重建）
+var rowKeyIndexes = []struct {
+	table string
+	name  string
+}{
+	{"attack_logs", "idx_attack_logs_row_key"},
+	{"tcp_logs", "idx_tcp_logs_row_key"},
+}
+
+// 初始表结构。既用于新建数据库，也用于升级无案件与行自然键的早期数据库：
+// 未归属案件的数据先归入默认案件，再回填行自然键并删除完全重复的行，最后建立唯一索引
+func createInitialSchema(tx *gorm.DB) error {
+	// 文件哈希唯一约束已改为按案件唯一
+	if tx.Migrator().HasIndex(&logFileV1{}, "idx_log_files_sha256") {
+		if err := tx.Migrator().DropIndex(&logFileV1{}, "idx_log_files_sha256"); err != nil {
+			return err
+		}
+	}
+	if err := tx.AutoMigrate(&caseV1{}, &attackLogV1{}, &tcpLogV1{}, &aptEventV1{},
+		&logFileV1{}, &quarantinedLineV1{}); err != nil {
+		return err
+	}
+
+	defaultCase := caseV1{Name: utils.DefaultCaseName, Description: "未指定案件的数据"}
+	if err := tx.Where("name = ?", utils.DefaultCaseName).FirstOrCreate(&defaultCase).Error; err != nil {
+		return err
+	}
+	for _, model := range []interface{}{&attackLogV1{}, &tcpLogV1{}, &aptEventV1{}} {
+		if err := tx.Model(model).Unscoped().Where("case_id = 0 OR case_id IS NULL").
+			Update("case_id", defaultCase.ID).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := backfillAttackRowKeys(tx); err != nil {
+		return err
+	}
+	if err := backfillTcpRowKeys(tx); err != nil {
+		return err
+	}
+	for _, idx := range rowKeyIndexes {
+		if tx.Migrator().HasIndex(idx.table, idx.name) {
+			continue
+		}
+		if err := dropDuplicateRows(tx, idx.table); err != nil {
+			return err
+		}
+		if err := tx.Exec("CREATE UNIQUE INDEX " + idx.name + " ON " + idx.table + " (row_key)").Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dropInitialSchema(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&quarantinedLineV1{}, &logFileV1{}, &aptEventV1{},
+		&tcpLogV1{}, &attackLogV1{}, &caseV1{})
+}
+
+// 按当前自然键算法为缺少行自然键的攻击日志回填
+func backfillAttackRowKeys(tx *gorm.DB) error {
+	var batch []attackLogV1
+	return tx.Where("row_key = '' OR row_key IS NULL").FindInBatches(&batch, rekeyBatchSize, func(_ *gorm.DB, _ int) error {
+		for _, row := range batch {
+			l := utils.AttackLog{
+				LogTime: row.LogTime, EventType: row.EventType, SourceIP: row.SourceIP, Protocol: row.Protocol,
+				Action: row.Action, DestIP: row.DestIP, Severity: row.Severity, CaseID: row.CaseID,
+			}
+			if err := tx.Model(&attackLogV1{}).Where("id = ?", row.ID).Update("row_key", l.NaturalKey()).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+// 按当前自然键算法为缺少行自然键的会话日志回填
+func backfillTcpRowKeys(tx *gorm.DB) error {
+	var batch []tcpLogV1
+	return tx.Where("row_key = '' OR row_key IS NULL").FindInBatches(&batch, rekeyBatchSize, func(_ *gorm.DB, _ int) error {
+		for _, row := range batch {
+			l := utils.TcpLog{
+				StartTime: row.StartTime, EndTime: row.EndTime, ClientIP: row.ClientIP, ClientPort: row.ClientPort,
+				ServerIP: row.ServerIP, ServerPort: row.ServerPort, Protocol: row.Protocol,
+				UpBytes: row.UpBytes, DownBytes: row.DownBytes, CaseID: row.CaseID,
+			}
+			if err := tx.Model(&tcpLogV1{}).Where("id = ?", row.ID).Update("row_key", l.NaturalKey()).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+// 自然键相同的行为重复导入的同一日志，保留最早的一行
+func dropDuplicateRows(tx *gorm.DB, table string) error {
+	result := tx.Exec("DELETE FROM " + table + " WHERE id NOT IN (SELECT id FROM (SELECT MIN(id) AS id FROM " +
+		table + " GROUP BY row_key) AS kept)")
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("删除%s中重复的日志%d行", table, result.RowsAffected)
+	}
+	return nil
+}
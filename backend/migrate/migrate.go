@@ -0,0 +1,150 @@
+package migrate
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"log"
+	"sort"
+	"time"
+)
+
+// 版本化的数据表迁移
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// 已执行的迁移记录
+type SchemaMigration struct {
+	Version   int    `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"type:varchar(100)"`
+	AppliedAt time.Time
+}
+
+// 迁移状态（status命令输出）
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"appliedAt,omitempty"`
+}
+
+// 最新的迁移版本
+func Latest() int {
+	return migrations[len(migrations)-1].Version
+}
+
+func applied(db *gorm.DB) (map[int]SchemaMigration, error) {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, fmt.Errorf("迁移记录表创建失败: %v", err)
+	}
+	var records []SchemaMigration
+	if err := db.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("迁移记录查询失败: %v", err)
+	}
+	done := make(map[int]SchemaMigration, len(records))
+	for _, r := range records {
+		done[r.Version] = r
+	}
+	return done, nil
+}
+
+// 执行未应用的迁移直至target版本（target<=0为最新版本），返回执行数量
+func Up(db *gorm.DB, target int) (int, error) {
+	if target <= 0 {
+		target = Latest()
+	}
+	done, err := applied(db)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range migrations {
+		if m.Version > target {
+			break
+		}
+		if _, ok := done[m.Version]; ok {
+			continue
+		}
+		log.Printf("执行迁移 %03d_%s", m.Version, m.Name)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return count, fmt.Errorf("迁移%03d_%s失败: %v", m.Version, m.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// 按版本倒序回滚最近steps个已应用的迁移，返回回滚数量
+func Down(db *gorm.DB, steps int) (int, error) {
+	done, err := applied(db)
+	if err != nil {
+		return 0, err
+	}
+
+	versions := make([]int, 0, len(done))
+	for v := range done {
+		versions = append(versions, v)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+
+	count := 0
+	for _, v := range versions {
+		if count >= steps {
+			break
+		}
+		m, ok := find(v)
+		if !ok {
+			return count, fmt.Errorf("未知的迁移版本: %d", v)
+		}
+		log.Printf("回滚迁移 %03d_%s", m.Version, m.Name)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, m.Version).Error
+		})
+		if err != nil {
+			return count, fmt.Errorf("回滚%03d_%s失败: %v", m.Version, m.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// 全部迁移及其执行状态
+func Status(db *gorm.DB) ([]MigrationStatus, error) {
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		s := MigrationStatus{Version: m.Version, Name: m.Name}
+		if r, ok := done[m.Version]; ok {
+			appliedAt := r.AppliedAt
+			s.Applied = true
+			s.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+func find(version int) (Migration, bool) {
+	for _, m := range migrations {
+		if m.Version == version {
+			return m, true
+		}
+	}
+	return Migration{}, false
+}
@@ -0,0 +1,120 @@
+package migrate
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+)
+
+// 全部迁移，按版本升序排列；已发布的迁移不可修改，变更需追加新版本。
+// 迁移只引用schema.go中对应版本的表结构快照
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "initial_schema",
+		Up:      createInitialSchema,
+		Down:    dropInitialSchema,
+	},
+	{
+		Version: 2,
+		Name:    "graph_tables",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&graphNodeV2{}, &graphEdgeV2{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&graphEdgeV2{}, &graphNodeV2{})
+		},
+	},
+	{
+		Version: 3,
+		Name:    "flow_time_indexes",
+		Up: func(tx *gorm.DB) error {
+			for _, idx := range flowIndexes {
+				if tx.Migrator().HasIndex(idx.table, idx.name) {
+					continue
+				}
+				if err := tx.Exec(fmt.Sprintf("CREATE INDEX %s ON %s (%s)", idx.name, idx.table, idx.columns)).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, idx := range flowIndexes {
+				if !tx.Migrator().HasIndex(idx.table, idx.name) {
+					continue
+				}
+				if err := tx.Migrator().DropIndex(idx.table, idx.name); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 4,
+		Name:    "partition_tcp_logs",
+		Up:      partitionTcpLogs,
+		Down:    unpartitionTcpLogs,
+	},
+	{
+		Version: 5,
+		Name:    "event_fingerprints",
+		Up:      addEventFingerprints,
+		Down:    dropEventFingerprints,
+	},
+	{
+		Version: 6,
+		Name:    "event_evidence",
+		Up:      addEventEvidence,
+		Down:    dropEventEvidence,
+	},
+	{
+		Version: 7,
+		Name:    "event_metadata",
+		Up:      addEventMetadata,
+		Down:    dropEventMetadata,
+	},
+	{
+		Version: 8,
+		Name:    "graph_kinds",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &graphNodeV8{}, "Kind"); err != nil {
+				return err
+			}
+			return addColumns(tx, &graphEdgeV8{}, "Kind")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropColumns(tx, &graphEdgeV8{}, "Kind"); err != nil {
+				return err
+			}
+			return dropColumns(tx, &graphNodeV8{}, "Kind")
+		},
+	},
+	{
+		Version: 9,
+		Name:    "log_file_status",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &logFileV9{}, "Status")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &logFileV9{}, "Status")
+		},
+	},
+	{
+		Version: 10,
+		Name:    "event_detector",
+		Up:      addEventDetector,
+		Down:    dropEventDetector,
+	},
+}
+
+// 检测查询按主机与时间范围检索会话
+var flowIndexes = []struct {
+	table   string
+	name    string
+	columns string
+}{
+	{"tcp_logs", "idx_tcp_logs_client_time", "client_ip, start_time"},
+	{"tcp_logs", "idx_tcp_logs_server_time", "server_ip, start_time"},
+	{"attack_logs", "idx_attack_logs_log_time", "log_time"},
+}
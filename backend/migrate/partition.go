@@ -0,0 +1,133 @@
+package migrate
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"log"
+	"strings"
+	"time"
+)
+
+// TCP会话表按start_time月分区（仅MySQL），超出末分区的数据落入pmax
+const (
+	tcpTable            = "tcp_logs"
+	partitionNameFormat = "p200601"
+	maxPartition        = "pmax"
+)
+
+func isMySQL(db *gorm.DB) bool {
+	return db.Dialector.Name() == "mysql"
+}
+
+func monthOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// 生成[from, to]各月的分区定义
+func monthPartitions(from, to time.Time) []string {
+	var defs []string
+	for m := monthOf(from); !m.After(monthOf(to)); m = m.AddDate(0, 1, 0) {
+		defs = append(defs, fmt.Sprintf("PARTITION %s VALUES LESS THAN (TO_DAYS('%s'))",
+			m.Format(partitionNameFormat), m.AddDate(0, 1, 0).Format("2006-01-02")))
+	}
+	return defs
+}
+
+func tcpTimeRange(db *gorm.DB) (time.Time, time.Time, bool, error) {
+	var r struct {
+		MinTime *time.Time
+		MaxTime *time.Time
+	}
+	if err := db.Raw("SELECT MIN(start_time) AS min_time, MAX(start_time) AS max_time FROM " + tcpTable).Scan(&r).Error; err != nil {
+		return time.Time{}, time.Time{}, false, err
+	}
+	if r.MinTime == nil || r.MaxTime == nil {
+		return time.Time{}, time.Time{}, false, nil
+	}
+	return *r.MinTime, *r.MaxTime, true, nil
+}
+
+// 分区表的主键与唯一索引必须包含分区列
+func partitionTcpLogs(tx *gorm.DB) error {
+	if !isMySQL(tx) {
+		log.Printf("当前数据库不支持分区，跳过TCP会话表分区")
+		return nil
+	}
+
+	from, to, ok, err := tcpTimeRange(tx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		from, to = time.Now(), time.Now()
+	}
+	defs := append(monthPartitions(from, to), fmt.Sprintf("PARTITION %s VALUES LESS THAN MAXVALUE", maxPartition))
+
+	for _, stmt := range []string{
+		"ALTER TABLE " + tcpTable + " MODIFY start_time datetime(3) NOT NULL, DROP PRIMARY KEY, ADD PRIMARY KEY (id, start_time)",
+		"ALTER TABLE " + tcpTable + " DROP INDEX idx_tcp_logs_row_key, ADD UNIQUE INDEX idx_tcp_logs_row_key (row_key, start_time)",
+		"ALTER TABLE " + tcpTable + " PARTITION BY RANGE (TO_DAYS(start_time)) (" + strings.Join(defs, ", ") + ")",
+	} {
+		if err := tx.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unpartitionTcpLogs(tx *gorm.DB) error {
+	if !isMySQL(tx) {
+		return nil
+	}
+	for _, stmt := range []string{
+		"ALTER TABLE " + tcpTable + " REMOVE PARTITIONING",
+		"ALTER TABLE " + tcpTable + " DROP INDEX idx_tcp_logs_row_key, ADD UNIQUE INDEX idx_tcp_logs_row_key (row_key)",
+		"ALTER TABLE " + tcpTable + " DROP PRIMARY KEY, ADD PRIMARY KEY (id)",
+	} {
+		if err := tx.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 为超出现有分区范围的会话补建月分区，非MySQL或未分区时不做处理
+func ExtendTcpPartitions(db *gorm.DB) error {
+	if !isMySQL(db) {
+		return nil
+	}
+
+	var names []string
+	if err := db.Raw(`SELECT PARTITION_NAME FROM information_schema.PARTITIONS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND PARTITION_NAME IS NOT NULL
+		ORDER BY PARTITION_ORDINAL_POSITION`, tcpTable).Scan(&names).Error; err != nil {
+		return fmt.Errorf("分区查询失败: %v", err)
+	}
+
+	var last time.Time
+	for _, name := range names {
+		if m, err := time.Parse(partitionNameFormat, name); err == nil && m.After(last) {
+			last = m
+		}
+	}
+	if last.IsZero() {
+		return nil
+	}
+
+	_, maxTime, ok, err := tcpTimeRange(db)
+	if err != nil {
+		return fmt.Errorf("会话时间范围查询失败: %v", err)
+	}
+	next := last.AddDate(0, 1, 0)
+	if !ok || maxTime.Before(next) {
+		return nil
+	}
+
+	defs := append(monthPartitions(next, maxTime), fmt.Sprintf("PARTITION %s VALUES LESS THAN MAXVALUE", maxPartition))
+	stmt := fmt.Sprintf("ALTER TABLE %s REORGANIZE PARTITION %s INTO (%s)", tcpTable, maxPartition, strings.Join(defs, ", "))
+	if err := db.Exec(stmt).Error; err != nil {
+		return fmt.Errorf("分区扩展失败: %v", err)
+	}
+	log.Printf("TCP会话表新增%d个月分区 (%s ~ %s)", len(defs)-1, next.Format("2006-01"), maxTime.Format("2006-01"))
+	return nil
+}
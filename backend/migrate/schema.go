@@ -0,0 +1,241 @@
+package migrate
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 各版本迁移使用的表结构快照。迁移只能引用对应版本的快照而非utils/store中的模型，
+// 否则模型新增字段后，早期版本会提前建出后续版本才应添加的列，各版本的表结构无法复现
+
+// v1 初始表结构
+type caseV1 struct {
+	ID          uint   `gorm:"primaryKey"`
+	Name        string `gorm:"type:varchar(100);uniqueIndex"`
+	Description string `gorm:"type:text"`
+	CreatedAt   time.Time
+}
+
+func (caseV1) TableName() string { return "cases" }
+
+// row_key唯一索引在回填后单独创建
+type attackLogV1 struct {
+	ID        uint      `gorm:"primaryKey"`
+	LogTime   time.Time `gorm:"column:log_time"`
+	EventType int       `gorm:"column:event_type"`
+	SourceIP  string    `gorm:"column:source_ip;type:varchar(45)"`
+	Protocol  int       `gorm:"column:protocol"`
+	Action    string    `gorm:"column:action"`
+	DestIP    string    `gorm:"column:dest_ip;type:varchar(45)"`
+	Severity  int       `gorm:"column:severity"`
+	CaseID    uint      `gorm:"column:case_id;index"`
+	FileID    uint      `gorm:"column:file_id;index"`
+	RowKey    string    `gorm:"column:row_key;type:char(40)"`
+}
+
+func (attackLogV1) TableName() string { return "attack_logs" }
+
+type tcpLogV1 struct {
+	ID              uint      `gorm:"primaryKey"`
+	LogTime         time.Time `gorm:"column:log_time"`
+	StartTime       time.Time `gorm:"column:start_time"`
+	EndTime         time.Time `gorm:"column:end_time"`
+	EstablishedTime time.Time `gorm:"column:established_time"`
+	FlowStatus      int       `gorm:"column:flow_status"`
+	Duration        float64   `gorm:"column:duration"`
+	ServerIP        string    `gorm:"column:server_ip;type:varchar(45)"`
+	ServerPort      int       `gorm:"column:server_port"`
+	ClientIP        string    `gorm:"column:client_ip;type:varchar(45)"`
+	ClientPort      int       `gorm:"column:client_port"`
+	TTLServer       int       `gorm:"column:ttl_server"`
+	TTLClient       int       `gorm:"column:ttl_client"`
+	Protocol        int       `gorm:"column:protocol"`
+	ClientPLR       float64   `gorm:"column:client_plr"`
+	ServerPLR       float64   `gorm:"column:server_plr"`
+	DownBPS         int64     `gorm:"column:down_bps"`
+	UpBPS           int64     `gorm:"column:up_bps"`
+	DownBytes       int64     `gorm:"column:down_bytes"`
+	UpBytes         int64     `gorm:"column:up_bytes"`
+	FragmentFlag    int       `gorm:"column:fragment_flag"`
+	StatusCode      int       `gorm:"column:status_code"`
+	PacketsSent     int       `gorm:"column:packets_sent"`
+	PacketReceive   int       `gorm:"column:packets_receive"`
+	CustomStatus    int       `gorm:"column:custom_status"`
+	CaseID          uint      `gorm:"column:case_id;index"`
+	FileID          uint      `gorm:"column:file_id;index"`
+	RowKey          string    `gorm:"column:row_key;type:char(40)"`
+}
+
+func (tcpLogV1) TableName() string { return "tcp_logs" }
+
+type aptEventV1 struct {
+	gorm.Model
+	CaseID        uint      `gorm:"index"`
+	StartTime     time.Time `gorm:"index"`
+	EndTime       time.Time `gorm:"index"`
+	SourceIP      string    `gorm:"type:varchar(45);index"`
+	DestIP        string    `gorm:"type:varchar(45);index"`
+	EventName     string    `gorm:"type:varchar(100)"`
+	EventType     string    `gorm:"type:varchar(50);index"`
+	SeverityLevel int       `gorm:"default:3"`
+	Description   string    `gorm:"type:text"`
+	Flags         int
+	SrcPort       int
+	DestPort      int
+	BytesSent     int64
+	BytesReceived int64
+	StatusCode    int
+	Retransmits   int
+	Protocol      string
+}
+
+func (aptEventV1) TableName() string { return eventTable }
+
+type logFileV1 struct {
+	ID         uint   `gorm:"primaryKey"`
+	CaseID     uint   `gorm:"uniqueIndex:idx_log_files_case_sha256,priority:1"`
+	Name       string `gorm:"type:varchar(255);index"`
+	SHA256     string `gorm:"column:sha256;type:char(64);uniqueIndex:idx_log_files_case_sha256,priority:2"`
+	Size       int64
+	UploadID   string `gorm:"type:varchar(32);index"`
+	Lines      int
+	Accepted   int
+	Rejected   int
+	Duplicates int
+	CreatedAt  time.Time
+}
+
+func (logFileV1) TableName() string { return "log_files" }
+
+type quarantinedLineV1 struct {
+	ID        uint   `gorm:"primaryKey"`
+	UploadID  string `gorm:"type:varchar(32);index"`
+	CaseID    uint   `gorm:"index"`
+	FileID    uint   `gorm:"index"`
+	File      string `gorm:"type:varchar(255);index"`
+	LogType   string `gorm:"type:varchar(16)"`
+	LineNo    int
+	Reason    string `gorm:"type:varchar(255)"`
+	Content   string `gorm:"type:text"`
+	CreatedAt time.Time
+}
+
+func (quarantinedLineV1) TableName() string { return "quarantined_lines" }
+
+// v2 嵌入式攻击图
+type graphNodeV2 struct {
+	ID        uint   `gorm:"primaryKey"`
+	CaseID    uint   `gorm:"uniqueIndex:idx_graph_nodes_case_phase,priority:1"`
+	Phase     string `gorm:"type:varchar(50);uniqueIndex:idx_graph_nodes_case_phase,priority:2"`
+	Timestamp time.Time
+	SourceIP  string `gorm:"type:varchar(45)"`
+	DestIP    string `gorm:"type:varchar(45)"`
+}
+
+func (graphNodeV2) TableName() string { return "graph_nodes" }
+
+type graphEdgeV2 struct {
+	ID          uint   `gorm:"primaryKey"`
+	CaseID      uint   `gorm:"uniqueIndex:idx_graph_edges_case_from_to,priority:1"`
+	FromPhase   string `gorm:"type:varchar(50);uniqueIndex:idx_graph_edges_case_from_to,priority:2"`
+	ToPhase     string `gorm:"type:varchar(50);uniqueIndex:idx_graph_edges_case_from_to,priority:3"`
+	Confidence  float64
+	Count       int
+	LastUpdated time.Time
+}
+
+func (graphEdgeV2) TableName() string { return "graph_edges" }
+
+// v5 事件指纹与分析水位
+type aptEventV5 struct {
+	Fingerprint string `gorm:"type:char(40)"`
+}
+
+func (aptEventV5) TableName() string { return eventTable }
+
+type analysisWatermarkV5 struct {
+	CaseID      uint `gorm:"primaryKey;autoIncrement:false"`
+	AttackLogID uint
+	TcpLogID    uint
+	UpdatedAt   time.Time
+}
+
+func (analysisWatermarkV5) TableName() string { return "analysis_watermarks" }
+
+// v6 触发次数与事件证据
+type aptEventV6 struct {
+	Occurrences int `gorm:"default:1"`
+}
+
+func (aptEventV6) TableName() string { return eventTable }
+
+type eventEvidenceV6 struct {
+	ID        uint   `gorm:"primaryKey"`
+	EventID   uint   `gorm:"uniqueIndex:idx_event_evidences_log,priority:1"`
+	LogType   string `gorm:"type:varchar(16);uniqueIndex:idx_event_evidences_log,priority:2"`
+	LogID     uint   `gorm:"uniqueIndex:idx_event_evidences_log,priority:3"`
+	CreatedAt time.Time
+}
+
+func (eventEvidenceV6) TableName() string { return "event_evidences" }
+
+// v7 事件结构化信息
+type aptEventV7 struct {
+	Metadata json.RawMessage `gorm:"type:text"`
+}
+
+func (aptEventV7) TableName() string { return eventTable }
+
+// v8 攻击图节点与边类别
+type graphNodeV8 struct {
+	Kind string `gorm:"type:varchar(16)"`
+}
+
+func (graphNodeV8) TableName() string { return "graph_nodes" }
+
+type graphEdgeV8 struct {
+	Kind string `gorm:"type:varchar(16)"`
+}
+
+func (graphEdgeV8) TableName() string { return "graph_edges" }
+
+// v9 文件导入状态，已有记录视为导入完成
+type logFileV9 struct {
+	Status string `gorm:"type:varchar(16);default:'complete'"`
+}
+
+func (logFileV9) TableName() string { return "log_files" }
+
+// v10 事件检测规则
+type aptEventV10 struct {
+	Detector string `gorm:"type:varchar(100)"`
+}
+
+func (aptEventV10) TableName() string { return eventTable }
+
+// 为表添加快照中的列，列已存在时跳过（早期版本的迁移曾按最新模型建表）
+func addColumns(tx *gorm.DB, snapshot interface{}, fields ...string) error {
+	for _, field := range fields {
+		if tx.Migrator().HasColumn(snapshot, field) {
+			continue
+		}
+		if err := tx.Migrator().AddColumn(snapshot, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dropColumns(tx *gorm.DB, snapshot interface{}, fields ...string) error {
+	for _, field := range fields {
+		if !tx.Migrator().HasColumn(snapshot, field) {
+			continue
+		}
+		if err := tx.Migrator().DropColumn(snapshot, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package model
+
+import (
+	"awesomeProject1/backend/utils"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 单个客户端/服务端对的信标特征
+type beaconStats struct {
+	ServerIP    string    `json:"serverIp"`
+	ServerPort  int       `json:"serverPort"`
+	Connections int       `json:"connections"`
+	Period      float64   `json:"periodSeconds"` // 估计周期（秒）
+	Jitter      float64   `json:"jitter"`        // 主周期附近间隔的中位偏差（相对周期）
+	Dispersion  float64   `json:"dispersion"`    // 全部间隔的变异系数
+	Regularity  float64   `json:"regularity"`    // 落在主周期附近的间隔占比
+	ByteJitter  float64   `json:"byteJitter"`    // 会话字节数的变异系数
+	FirstSeen   time.Time `json:"firstSeen"`
+	LastSeen    time.Time `json:"lastSeen"`
+	flowIDs     []uint
+}
+
+// 检测规则5：周期性信标通信
+// 按服务端IP与端口分组，分析会话开始时间的间隔直方图与字节数的规律性
+func (a *NAAnalyzer) detectBeaconing(flows []utils.TcpLog) DetectionResult {
+	t := a.flowThresholds(flows)
+
+	groups := make(map[string][]utils.TcpLog)
+	for _, f := range flows {
+		key := fmt.Sprintf("%s:%d", f.ServerIP, f.ServerPort)
+		groups[key] = append(groups[key], f)
+	}
+
+	var beacons []beaconStats
+	for _, members := range groups {
+		if len(members) < t.BeaconMinConns {
+			continue
+		}
+		if s, ok := analyzeBeacon(members, t); ok {
+			beacons = append(beacons, s)
+		}
+	}
+	if len(beacons) == 0 {
+		return DetectionResult{Triggered: false}
+	}
+
+	sort.Slice(beacons, func(i, j int) bool {
+		if beacons[i].Connections != beacons[j].Connections {
+			return beacons[i].Connections > beacons[j].Connections
+		}
+		if beacons[i].ServerIP != beacons[j].ServerIP {
+			return beacons[i].ServerIP < beacons[j].ServerIP
+		}
+		return beacons[i].ServerPort < beacons[j].ServerPort
+	})
+
+	var ids []uint
+	parts := make([]string, 0, len(beacons))
+	for _, b := range beacons {
+		ids = append(ids, b.flowIDs...)
+		parts = append(parts, fmt.Sprintf("%s:%d 周期%s 抖动%.0f%% (%d次)", b.ServerIP, b.ServerPort,
+			time.Duration(b.Period*float64(time.Second)).Round(time.Second), b.Jitter*100, b.Connections))
+	}
+	metadata, _ := json.Marshal(map[string]interface{}{"beacons": beacons})
+
+	return DetectionResult{
+		Triggered:     true,
+		EventName:     EventC2Communication,
+		EventType:     PhaseC2,
+		Description:   "发现周期性信标通信: " + strings.Join(parts, "; "),
+		SeverityLevel: 4,
+		FlowIDs:       ids,
+		Metadata:      metadata,
+	}
+}
+
+// 分析同一服务端的会话是否呈现信标特征
+func analyzeBeacon(flows []utils.TcpLog, t Thresholds) (beaconStats, bool) {
+	sort.Slice(flows, func(i, j int) bool {
+		return flows[i].StartTime.Before(flows[j].StartTime)
+	})
+
+	// 同一时刻的多个会话视为一次回连
+	var intervals []float64
+	for i := 1; i < len(flows); i++ {
+		if d := flows[i].StartTime.Sub(flows[i-1].StartTime).Seconds(); d > 0 {
+			intervals = append(intervals, d)
+		}
+	}
+	if len(intervals) < t.BeaconMinConns-1 {
+		return beaconStats{}, false
+	}
+
+	// 间隔直方图：桶宽取中位间隔的允许抖动，主周期为计数最多的桶及其相邻桶
+	width := math.Max(1, median(intervals)*t.BeaconMaxJitter)
+	bins := make(map[int]int)
+	for _, d := range intervals {
+		bins[int(math.Round(d/width))]++
+	}
+	mode, modeCount := 0, 0
+	for bin, cnt := range bins {
+		if cnt > modeCount || (cnt == modeCount && bin < mode) {
+			mode, modeCount = bin, cnt
+		}
+	}
+	var dominant []float64
+	for _, d := range intervals {
+		if bin := int(math.Round(d / width)); bin >= mode-1 && bin <= mode+1 {
+			dominant = append(dominant, d)
+		}
+	}
+
+	period := median(dominant)
+	deviations := make([]float64, len(dominant))
+	for i, d := range dominant {
+		deviations[i] = math.Abs(d - period)
+	}
+
+	bytes := make([]float64, len(flows))
+	for i, f := range flows {
+		bytes[i] = float64(f.UpBytes + f.DownBytes)
+	}
+
+	s := beaconStats{
+		ServerIP:    flows[0].ServerIP,
+		ServerPort:  flows[0].ServerPort,
+		Connections: len(flows),
+		Period:      period,
+		Jitter:      median(deviations) / period,
+		Dispersion:  variation(intervals),
+		Regularity:  float64(len(dominant)) / float64(len(intervals)),
+		ByteJitter:  variation(bytes),
+		FirstSeen:   flows[0].StartTime,
+		LastSeen:    flows[len(flows)-1].StartTime,
+		flowIDs:     flowIDs(flows),
+	}
+	ok := period >= t.BeaconMinPeriod.Seconds() &&
+		s.Jitter <= t.BeaconMaxJitter &&
+		s.Regularity >= t.BeaconMinRegularity &&
+		s.ByteJitter <= t.BeaconMaxByteJitter
+	return s, ok
+}
+
+// 中位数
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// 变异系数（标准差/均值），均值为0时返回0
+func variation(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	if mean == 0 {
+		return 0
+	}
+	var sq float64
+	for _, v := range values {
+		sq += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(sq/float64(len(values))) / mean
+}
@@ -0,0 +1,113 @@
+package model
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"awesomeProject1/backend/utils"
+)
+
+var testBase = time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+
+// 按给定间隔（秒）与字节数生成同一服务端的会话
+func beaconFlows(intervals []float64, bytes []int64) []utils.TcpLog {
+	flows := make([]utils.TcpLog, 0, len(intervals)+1)
+	at := testBase
+	for i := 0; i <= len(intervals); i++ {
+		if i > 0 {
+			at = at.Add(time.Duration(intervals[i-1] * float64(time.Second)))
+		}
+		b := int64(1000)
+		if bytes != nil {
+			b = bytes[i]
+		}
+		flows = append(flows, utils.TcpLog{
+			ID: uint(i + 1), StartTime: at, ClientIP: "10.0.0.5",
+			ServerIP: "203.0.113.7", ServerPort: 443, UpBytes: b,
+		})
+	}
+	return flows
+}
+
+func TestAnalyzeBeacon(t *testing.T) {
+	tests := []struct {
+		name       string
+		intervals  []float64
+		bytes      []int64
+		want       bool
+		wantPeriod float64
+	}{
+		{
+			name:       "固定周期",
+			intervals:  []float64{60, 60, 60, 60, 60, 60, 60},
+			want:       true,
+			wantPeriod: 60,
+		},
+		{
+			name:       "小幅抖动",
+			intervals:  []float64{58, 61, 60, 62, 59, 60, 61},
+			want:       true,
+			wantPeriod: 60,
+		},
+		{
+			// 偶发的长间隔（如主机休眠）不影响主周期
+			name:       "少量离群间隔",
+			intervals:  []float64{300, 300, 300, 2400, 300, 300, 300, 300, 300, 300},
+			want:       true,
+			wantPeriod: 300,
+		},
+		{
+			name:      "随机间隔",
+			intervals: []float64{5, 400, 37, 1200, 90, 15, 700},
+			want:      false,
+		},
+		{
+			name:      "周期过短",
+			intervals: []float64{2, 2, 2, 2, 2, 2, 2},
+			want:      false,
+		},
+		{
+			name:      "字节数不稳定",
+			intervals: []float64{60, 60, 60, 60, 60, 60, 60},
+			bytes:     []int64{100, 50000, 200, 90000, 150, 70000, 120, 80000},
+			want:      false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, ok := analyzeBeacon(beaconFlows(tt.intervals, tt.bytes), DefaultThresholds())
+			if ok != tt.want {
+				t.Fatalf("analyzeBeacon() = %v (%+v), want %v", ok, s, tt.want)
+			}
+			if tt.want && math.Abs(s.Period-tt.wantPeriod) > 1 {
+				t.Errorf("Period = %.1f, want %.1f", s.Period, tt.wantPeriod)
+			}
+			if s.Connections != len(tt.intervals)+1 {
+				t.Errorf("Connections = %d, want %d", s.Connections, len(tt.intervals)+1)
+			}
+		})
+	}
+}
+
+func TestMedianAndVariation(t *testing.T) {
+	tests := []struct {
+		values        []float64
+		wantMedian    float64
+		wantVariation float64
+	}{
+		{nil, 0, 0},
+		{[]float64{5}, 5, 0},
+		{[]float64{3, 1, 2}, 2, math.Sqrt(2.0/3) / 2},
+		{[]float64{4, 1, 3, 2}, 2.5, math.Sqrt(1.25) / 2.5},
+		{[]float64{0, 0}, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := median(tt.values); got != tt.wantMedian {
+			t.Errorf("median(%v) = %v, want %v", tt.values, got, tt.wantMedian)
+		}
+		if got := variation(tt.values); math.Abs(got-tt.wantVariation) > 1e-9 {
+			t.Errorf("variation(%v) = %v, want %v", tt.values, got, tt.wantVariation)
+		}
+	}
+}
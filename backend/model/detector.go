@@ -0,0 +1,286 @@
+package model
+
+import (
+	"awesomeProject1/backend/utils"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 检测规则作用对象
+type DetectorScope string
+
+const (
+	ScopeAttacker DetectorScope = "attacker" // 攻击者行为（攻击前窗口）
+	ScopeVictim   DetectorScope = "victim"   // 受害者行为（攻击后窗口）
+	ScopeZombie   DetectorScope = "zombie"   // 肉鸡行为（受害者外联对象）
+	ScopeService  DetectorScope = "service"  // 已知攻击者对单个目标服务的会话（分析窗口内按事件时段分组）
+)
+
+// 检测输入：当前窗口内的TCP会话及触发分析的攻击日志
+type DetectionInput struct {
+	Flows  []utils.TcpLog
+	Attack utils.AttackLog // service规则为已知攻击者与目标构造的攻击日志，无ID
+	Start  time.Time       // 检测窗口起点，即事件开始时间
+}
+
+// 检测规则接口，自定义规则实现后通过RegisterDetector注册
+type Detector interface {
+	Name() string
+	Scope() DetectorScope
+	Description() string
+	Detect(a *NAAnalyzer, in DetectionInput) DetectionResult
+}
+
+// 规则描述（供API展示）
+type DetectorInfo struct {
+	Name        string        `json:"name"`
+	Scope       DetectorScope `json:"scope"`
+	Description string        `json:"description"`
+	Enabled     bool          `json:"enabled"`
+}
+
+type funcDetector struct {
+	name        string
+	scope       DetectorScope
+	description string
+	detect      func(*NAAnalyzer, DetectionInput) DetectionResult
+}
+
+func (d *funcDetector) Name() string         { return d.name }
+func (d *funcDetector) Scope() DetectorScope { return d.scope }
+func (d *funcDetector) Description() string  { return d.description }
+
+func (d *funcDetector) Detect(a *NAAnalyzer, in DetectionInput) DetectionResult {
+	return d.detect(a, in)
+}
+
+// 由函数构造检测规则
+func NewDetector(name string, scope DetectorScope, description string,
+	detect func(*NAAnalyzer, DetectionInput) DetectionResult) Detector {
+	return &funcDetector{
+		name:        name,
+		scope:       scope,
+		description: description,
+		detect:      detect,
+	}
+}
+
+// 适配仅依赖会话列表的规则
+func flowRule(fn func(*NAAnalyzer, []utils.TcpLog) DetectionResult) func(*NAAnalyzer, DetectionInput) DetectionResult {
+	return func(a *NAAnalyzer, in DetectionInput) DetectionResult {
+		return fn(a, in.Flows)
+	}
+}
+
+// 适配肉鸡规则（需要攻击者IP）
+func zombieRule(fn func(*NAAnalyzer, []utils.TcpLog, string) DetectionResult) func(*NAAnalyzer, DetectionInput) DetectionResult {
+	return func(a *NAAnalyzer, in DetectionInput) DetectionResult {
+		return fn(a, in.Flows, in.Attack.SourceIP)
+	}
+}
+
+// 检测规则注册表
+type DetectorRegistry struct {
+	mu        sync.RWMutex
+	detectors map[string]Detector
+	order     []string
+	disabled  map[string]bool
+}
+
+func NewDetectorRegistry() *DetectorRegistry {
+	return &DetectorRegistry{
+		detectors: make(map[string]Detector),
+		disabled:  make(map[string]bool),
+	}
+}
+
+// 默认注册表，内置规则在init中注册
+var DefaultRegistry = NewDetectorRegistry()
+
+func RegisterDetector(d Detector) error {
+	return DefaultRegistry.Register(d)
+}
+
+func (r *DetectorRegistry) Register(d Detector) error {
+	if err := checkDetector(d); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.detectors[d.Name()]; exists {
+		return fmt.Errorf("检测规则已存在: %s", d.Name())
+	}
+	r.detectors[d.Name()] = d
+	r.order = append(r.order, d.Name())
+	return nil
+}
+
+// 以detectors替换名为old的规则：先校验全部新规则，任一规则无效或与其余已注册规则重名时
+// 注册表保持不变
+func (r *DetectorRegistry) Replace(old []string, detectors []Detector) error {
+	replaced := make(map[string]bool, len(old))
+	for _, name := range old {
+		replaced[name] = true
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	seen := make(map[string]bool, len(detectors))
+	for _, d := range detectors {
+		if err := checkDetector(d); err != nil {
+			return err
+		}
+		if _, exists := r.detectors[d.Name()]; (exists && !replaced[d.Name()]) || seen[d.Name()] {
+			return fmt.Errorf("检测规则已存在: %s", d.Name())
+		}
+		seen[d.Name()] = true
+	}
+
+	order := r.order[:0:0]
+	for _, name := range r.order {
+		if replaced[name] {
+			delete(r.detectors, name)
+			continue
+		}
+		order = append(order, name)
+	}
+	for _, d := range detectors {
+		r.detectors[d.Name()] = d
+		order = append(order, d.Name())
+	}
+	r.order = order
+	return nil
+}
+
+func checkDetector(d Detector) error {
+	if d == nil || d.Name() == "" {
+		return fmt.Errorf("检测规则名称不能为空")
+	}
+	switch d.Scope() {
+	case ScopeAttacker, ScopeVictim, ScopeZombie, ScopeService:
+	default:
+		return fmt.Errorf("检测规则%s作用对象无效: %s", d.Name(), d.Scope())
+	}
+	return nil
+}
+
+// 注销规则，保留其全局启用状态以便重新注册后沿用
+func (r *DetectorRegistry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.detectors[name]; !exists {
+		return
+	}
+	delete(r.detectors, name)
+	for i, n := range r.order {
+		if n == name {
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			break
+		}
+	}
+}
+
+func (r *DetectorRegistry) mustRegister(d Detector) {
+	if err := r.Register(d); err != nil {
+		panic(err)
+	}
+}
+
+// 全局启用/禁用规则
+func (r *DetectorRegistry) SetEnabled(name string, enabled bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.detectors[name]; !exists {
+		return fmt.Errorf("检测规则不存在: %s", name)
+	}
+	if enabled {
+		delete(r.disabled, name)
+	} else {
+		r.disabled[name] = true
+	}
+	return nil
+}
+
+func (r *DetectorRegistry) Has(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, exists := r.detectors[name]
+	return exists
+}
+
+func (r *DetectorRegistry) List() []DetectorInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	infos := make([]DetectorInfo, 0, len(r.order))
+	for _, name := range r.order {
+		d := r.detectors[name]
+		infos = append(infos, DetectorInfo{
+			Name:        name,
+			Scope:       d.Scope(),
+			Description: d.Description(),
+			Enabled:     !r.disabled[name],
+		})
+	}
+	sort.SliceStable(infos, func(i, j int) bool {
+		return infos[i].Scope < infos[j].Scope
+	})
+	return infos
+}
+
+// 按作用对象获取本次运行启用的规则，overrides为单次运行的启用/禁用覆盖
+func (r *DetectorRegistry) active(scope DetectorScope, overrides map[string]bool) []Detector {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var detectors []Detector
+	for _, name := range r.order {
+		d := r.detectors[name]
+		if d.Scope() != scope {
+			continue
+		}
+		enabled := !r.disabled[name]
+		if v, ok := overrides[name]; ok {
+			enabled = v
+		}
+		if enabled {
+			detectors = append(detectors, d)
+		}
+	}
+	return detectors
+}
+
+func init() {
+	builtins := []Detector{
+		// 攻击者规则
+		NewDetector("connection_frequency", ScopeAttacker, "高频连接检测", flowRule((*NAAnalyzer).detectConnectionFrequency)),
+		NewDetector("new_ip_connections", ScopeAttacker, "新IP连接检测", (*NAAnalyzer).detectNewIPConnections),
+		NewDetector("port_scan", ScopeAttacker, "端口扫描检测", flowRule((*NAAnalyzer).detectPortScanPattern)),
+		NewDetector("protocol_anomaly", ScopeAttacker, "协议异常检测", flowRule((*NAAnalyzer).detectProtocolAnomalies)),
+
+		// 受害者规则
+		NewDetector("victim_outbound", ScopeVictim, "外联异常检测", flowRule((*NAAnalyzer).detectVictimOutbound)),
+		NewDetector("data_exfiltration", ScopeVictim, "数据渗出检测", flowRule((*NAAnalyzer).detectDataExfiltration)),
+		NewDetector("malicious_connection", ScopeVictim, "恶意连接检测", flowRule((*NAAnalyzer).detectMaliciousConnections)),
+		NewDetector("c2_long_connection", ScopeVictim, "C2长连接检测", flowRule((*NAAnalyzer).detectC2Communication)),
+		NewDetector("beaconing", ScopeVictim, "周期性信标通信检测", flowRule((*NAAnalyzer).detectBeaconing)),
+		NewDetector("lateral_movement", ScopeVictim, "内网横向移动检测", (*NAAnalyzer).detectLateralMovement),
+		NewDetector("pivot_chain", ScopeVictim, "跳板链检测", (*NAAnalyzer).detectPivotChain),
+		NewDetector("trojan_implant", ScopeVictim, "木马植入检测", (*NAAnalyzer).detectTrojanImplant),
+
+		// 肉鸡规则
+		NewDetector("zombie_new_connections", ScopeZombie, "肉鸡新IP连接检测", zombieRule((*NAAnalyzer).detectZombieNewConnections)),
+		NewDetector("zombie_reverse_connection", ScopeZombie, "肉鸡反向连接检测", zombieRule((*NAAnalyzer).detectZombieReverseConn)),
+		NewDetector("zombie_activity_spike", ScopeZombie, "肉鸡活动激增检测", zombieRule((*NAAnalyzer).detectZombieActivitySpike)),
+		NewDetector("zombie_malicious_connection", ScopeZombie, "肉鸡恶意连接检测", zombieRule((*NAAnalyzer).detectZombieMaliciousConn)),
+
+		// 目标服务规则
+		NewDetector(hiddenAttackDetector, ScopeService, "攻击日志未记录的攻击会话发现", (*NAAnalyzer).detectHiddenAttack),
+	}
+	for _, d := range builtins {
+		DefaultRegistry.mustRegister(d)
+	}
+}
@@ -0,0 +1,205 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func testDetector(name string, scope DetectorScope) Detector {
+	return NewDetector(name, scope, name, func(*NAAnalyzer, DetectionInput) DetectionResult {
+		return DetectionResult{}
+	})
+}
+
+func registryNames(r *DetectorRegistry) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]string(nil), r.order...)
+}
+
+func detectorNames(detectors []Detector) []string {
+	var names []string
+	for _, d := range detectors {
+		names = append(names, d.Name())
+	}
+	return names
+}
+
+func TestRegistryRegister(t *testing.T) {
+	r := NewDetectorRegistry()
+	r.mustRegister(testDetector("port_scan", ScopeAttacker))
+
+	tests := []struct {
+		name string
+		d    Detector
+	}{
+		{"重名", testDetector("port_scan", ScopeVictim)},
+		{"名称为空", testDetector("", ScopeAttacker)},
+		{"作用对象无效", testDetector("rule_a", "unknown")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := r.Register(tt.d); err == nil {
+				t.Error("Register() 未返回错误")
+			}
+		})
+	}
+	if got := registryNames(r); !reflect.DeepEqual(got, []string{"port_scan"}) {
+		t.Errorf("注册表 = %v, want [port_scan]", got)
+	}
+
+	r.Unregister("port_scan")
+	if r.Has("port_scan") {
+		t.Error("Unregister后规则仍存在")
+	}
+}
+
+func TestRegistryActive(t *testing.T) {
+	r := NewDetectorRegistry()
+	r.mustRegister(testDetector("a1", ScopeAttacker))
+	r.mustRegister(testDetector("v1", ScopeVictim))
+	r.mustRegister(testDetector("a2", ScopeAttacker))
+	r.mustRegister(testDetector("a3", ScopeAttacker))
+	if err := r.SetEnabled("a2", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetEnabled("missing", false); err == nil {
+		t.Error("SetEnabled() 未知规则未返回错误")
+	}
+
+	tests := []struct {
+		name      string
+		overrides map[string]bool
+		want      []string
+	}{
+		{"按注册顺序返回启用的规则", nil, []string{"a1", "a3"}},
+		{"单次运行启用", map[string]bool{"a2": true}, []string{"a1", "a2", "a3"}},
+		{"单次运行禁用", map[string]bool{"a1": false}, []string{"a3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectorNames(r.active(ScopeAttacker, tt.overrides)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("active() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistryReplace(t *testing.T) {
+	tests := []struct {
+		name      string
+		old       []string
+		detectors []Detector
+		want      []string
+		wantErr   bool
+	}{
+		{
+			name:      "替换上次加载的规则",
+			old:       []string{"rule_a"},
+			detectors: []Detector{testDetector("rule_b", ScopeVictim), testDetector("rule_c", ScopeAttacker)},
+			want:      []string{"port_scan", "rule_b", "rule_c"},
+		},
+		{
+			name:      "保留同名规则",
+			old:       []string{"rule_a"},
+			detectors: []Detector{testDetector("rule_a", ScopeVictim)},
+			want:      []string{"port_scan", "rule_a"},
+		},
+		{
+			name:      "与内置规则重名",
+			old:       []string{"rule_a"},
+			detectors: []Detector{testDetector("rule_b", ScopeVictim), testDetector("port_scan", ScopeAttacker)},
+			wantErr:   true,
+		},
+		{
+			name:      "新规则重名",
+			old:       []string{"rule_a"},
+			detectors: []Detector{testDetector("rule_b", ScopeVictim), testDetector("rule_b", ScopeAttacker)},
+			wantErr:   true,
+		},
+		{
+			name:      "作用对象无效",
+			old:       []string{"rule_a"},
+			detectors: []Detector{testDetector("rule_b", "unknown")},
+			wantErr:   true,
+		},
+		{
+			name:      "名称为空",
+			detectors: []Detector{testDetector("", ScopeAttacker)},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewDetectorRegistry()
+			r.mustRegister(testDetector("port_scan", ScopeAttacker))
+			r.mustRegister(testDetector("rule_a", ScopeAttacker))
+			before := registryNames(r)
+
+			err := r.Replace(tt.old, tt.detectors)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Replace() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			want := tt.want
+			if tt.wantErr {
+				want = before // 失败时注册表保持不变
+			}
+			if got := registryNames(r); !reflect.DeepEqual(got, want) {
+				t.Errorf("注册表 = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestLoadRuleFileKeepsRulesOnError(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	good := write("good.yaml", `
+rules:
+  - name: test_sweep
+    expr: count(distinct server_port) > 20 within 5m group by client_ip
+    eventType: LateralMovement
+    severity: 4
+`)
+	collide := write("collide.yaml", `
+rules:
+  - name: test_other
+    expr: count(*) > 1
+    eventType: LateralMovement
+    severity: 4
+  - name: port_scan
+    expr: count(*) > 1
+    eventType: InitialAccess
+    severity: 3
+`)
+	empty := write("empty.yaml", "rules: []\n")
+	t.Cleanup(func() { LoadRuleFile(empty) })
+
+	if err := LoadRuleFile(good); err != nil {
+		t.Fatalf("LoadRuleFile(good) error = %v", err)
+	}
+	if err := LoadRuleFile(collide); err == nil {
+		t.Fatal("与内置规则重名时LoadRuleFile未返回错误")
+	}
+	if !DefaultRegistry.Has("test_sweep") || DefaultRegistry.Has("test_other") {
+		t.Error("加载失败后应保留上次加载的规则且不注册新规则")
+	}
+	if !DefaultRegistry.Has("port_scan") {
+		t.Error("加载失败后内置规则被注销")
+	}
+
+	if err := LoadRuleFile(empty); err != nil {
+		t.Fatalf("LoadRuleFile(empty) error = %v", err)
+	}
+	if DefaultRegistry.Has("test_sweep") {
+		t.Error("重新加载后未注销上次加载的规则")
+	}
+}
@@ -2,13 +2,15 @@ package model
 
 import (
 	"awesomeProject1/backend/utils"
+	"context"
+	"encoding/json"
 	"fmt"
-	"gorm.io/gorm"
 	"log"
 	"sync"
 	"time"
 )
 
+// 内置默认阈值，运行时以阈值配置文件为准（见thresholds.go）
 const (
 	preAttackWindow      = 24 * time.Hour // 攻击前分析时间窗口
 	postAttackWindow     = 48 * time.Hour // 攻击后分析时间窗口
@@ -17,7 +19,7 @@ const (
 	maliciousIPCheck     = true           // 是否启用恶意IP检查
 	zombieWindow         = 72 * time.Hour // 肉鸡检测时间窗口
 	zombieNewIPThreshold = 10             // 肉鸡新IP连接阈值
-	zombieConnThreshold  = 100            // 肉鸡连接数阈值
+	zombieConnThreshold  = 100            // 肉鸡活动激增：窗口内最少连接数
 )
 
 // 新增事件类型常量定义
@@ -33,6 +35,10 @@ const (
 	EventMaliciousConnection = "MaliciousConnection"
 	EventZombieSpike         = "ZombieSpike"
 	EventLongConnection      = "LongConnection"
+	EventLateralMovement     = "LateralMovement"
+	EventPivotChain          = "PivotChain"
+	EventTrojanImplant       = "TrojanImplant"
+	EventHiddenAttack        = "HiddenAttack"
 )
 
 type DetectionResult struct {
@@ -41,16 +47,26 @@ type DetectionResult struct {
 	EventType     string
 	Description   string
 	SeverityLevel int
+	FlowIDs       []uint          // 触发规则的会话ID，作为事件证据
+	Metadata      json.RawMessage // 结构化检测信息（JSON），随事件保存
 }
 
 var (
-	maliciousIPs = []string{} // 恶意服务器ip
+	maliciousIPs     = []string{}                                                // 恶意服务器ip
+	internalNetworks = []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"} // 内网网段
+	lateralPorts     = []int{22, 135, 445, 3389, 5985, 5986}                     // SSH、RPC、SMB、RDP、WinRM
 )
 
 type NAAnalyzer struct {
-	db         *gorm.DB
-	ipProfiles sync.Map // IP行为画像缓存
-	attackMap  sync.Map // 攻击关系映射
+	flows      FlowStore
+	events     EventStore
+	caseID     uint                              // 分析的案件，仅读取并写入该案件的数据
+	ipProfiles sync.Map                          // IP行为画像缓存
+	attackMap  sync.Map                          // 攻击关系映射
+	recorded   map[string]map[string][]time.Time // 已知攻击者 -> 目标 -> 攻击日志时间，由analyzeServices建立
+	registry   *DetectorRegistry
+	overrides  map[string]bool  // 本次运行的规则启用/禁用覆盖
+	thresholds *ThresholdConfig // 本次运行使用的阈值快照
 }
 
 type IPProfile struct {
@@ -59,26 +75,57 @@ type IPProfile struct {
 	Connections   map[string]int
 	TotalConnects int
 	LastUpdated   time.Time
+	Before        time.Time // 画像统计的历史截止时间
 }
 
-func NewAnalyzer(db *gorm.DB) *NAAnalyzer {
+func NewAnalyzer(flows FlowStore, events EventStore, caseID uint) *NAAnalyzer {
 	return &NAAnalyzer{
-		db: db,
+		flows:      flows,
+		events:     events,
+		caseID:     caseID,
+		registry:   DefaultRegistry,
+		overrides:  make(map[string]bool),
+		thresholds: CurrentThresholds(),
 	}
 }
 
-// 主分析入口
-func (a *NAAnalyzer) RunAnalysis() {
-	var attacks []utils.AttackLog
-	if err := a.db.Find(&attacks).Error; err != nil {
+// 获取指定主机适用的阈值
+func (a *NAAnalyzer) thresholdsFor(ip string) Thresholds {
+	return a.thresholds.For(ip)
+}
+
+// 获取会话所属主机适用的阈值（同一窗口内的会话客户端IP相同）
+func (a *NAAnalyzer) flowThresholds(flows []utils.TcpLog) Thresholds {
+	if len(flows) == 0 {
+		return a.thresholds.Default
+	}
+	return a.thresholdsFor(flows[0].ClientIP)
+}
+
+// 仅对本次运行启用/禁用指定规则，不影响注册表的全局状态
+func (a *NAAnalyzer) SetDetectorEnabled(name string, enabled bool) error {
+	if !a.registry.Has(name) {
+		return fmt.Errorf("检测规则不存在: %s", name)
+	}
+	a.overrides[name] = enabled
+	return nil
+}
+
+// 主分析入口，分析window内发生的攻击日志
+func (a *NAAnalyzer) RunAnalysis(ctx context.Context, window TimeWindow) error {
+	attacks, err := a.flows.AttackLogs(a.caseID, window.Start, window.End)
+	if err != nil {
 		log.Printf("攻击日志查询失败: %v", err)
-		return
+		return err
 	}
 
 	var wg sync.WaitGroup
 	sem := make(chan struct{}, 10) // 并发控制
 
 	for _, attack := range attacks {
+		if ctx.Err() != nil {
+			break
+		}
 		wg.Add(1)
 		sem <- struct{}{}
 
@@ -97,41 +144,48 @@ func (a *NAAnalyzer) RunAnalysis() {
 	}
 
 	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// 攻击日志可能漏报，回查已知攻击者的全部会话
+	return a.analyzeServices(ctx, window)
+}
+
+// 查询主机在时间窗口内发起的会话，查询失败时按无会话处理
+func (a *NAAnalyzer) loadFlows(clientIP string, start, end time.Time) []utils.TcpLog {
+	flows, err := a.flows.Flows(a.caseID, clientIP, start, end)
+	if err != nil {
+		log.Printf("会话日志查询失败: %v", err)
+	}
+	return flows
 }
 
 // 攻击者行为分析
 func (a *NAAnalyzer) analyzeAttacker(attack utils.AttackLog) {
-	timeWindow := preAttackWindow
+	timeWindow := a.thresholdsFor(attack.SourceIP).PreAttackWindow
 	startTime := attack.LogTime.Add(-timeWindow)
 	endTime := attack.LogTime
 
-	var flows []utils.TcpLog
-	a.db.Where("client_ip = ? AND start_time BETWEEN ? AND ?",
-		attack.SourceIP,
-		startTime,
-		endTime,
-	).Find(&flows)
+	flows := a.loadFlows(attack.SourceIP, startTime, endTime)
 
-	// 更新检测规则集合
-	detections := []func([]utils.TcpLog) DetectionResult{
-		a.detectConnectionFrequency, // 高频连接检测
-		a.detectNewIPConnections,    // 新IP连接检测
-		a.detectPortScanPattern,     // 端口扫描检测
-		a.detectProtocolAnomalies,   // 协议异常检测
-	}
+	input := DetectionInput{Flows: flows, Attack: attack, Start: startTime}
 
 	var events []utils.APTEvent
-	for _, detect := range detections {
-		if result := detect(flows); result.Triggered {
+	for _, detector := range a.registry.active(ScopeAttacker, a.overrides) {
+		if result := detector.Detect(a, input); result.Triggered {
 			events = append(events, utils.APTEvent{
 				StartTime:     startTime,
 				EndTime:       endTime,
 				SourceIP:      attack.SourceIP,
 				DestIP:        attack.DestIP,
+				Detector:      detector.Name(),
 				EventName:     result.EventName,
 				EventType:     result.EventType,
 				SeverityLevel: result.SeverityLevel,
 				Description:   result.Description,
+				Metadata:      result.Metadata,
+				Evidence:      detectionEvidence(attack, result),
 			})
 		}
 	}
@@ -142,38 +196,30 @@ func (a *NAAnalyzer) analyzeAttacker(attack utils.AttackLog) {
 // 受害者行为分析
 func (a *NAAnalyzer) analyzeVictim(attack utils.AttackLog) {
 	startTime := attack.LogTime
-	endTime := attack.LogTime.Add(postAttackWindow)
+	endTime := attack.LogTime.Add(a.thresholdsFor(attack.DestIP).PostAttackWindow)
 
-	var flows []utils.TcpLog
-	a.db.Where("client_ip = ? AND start_time BETWEEN ? AND ?",
-		attack.DestIP,
-		startTime,
-		endTime,
-	).Find(&flows)
-
-	// 更新检测规则集合
-	detections := []func([]utils.TcpLog) DetectionResult{
-		a.detectVictimOutbound,       // 外联异常检测
-		a.detectDataExfiltration,     // 数据渗出检测
-		a.detectMaliciousConnections, // 恶意连接检测
-		a.detectC2Communication,      // 新增C2通信检测
-	}
+	flows := a.loadFlows(attack.DestIP, startTime, endTime)
 
 	zombieIPs := a.collectZombieIPs(flows)
 	a.analyzeZombies(zombieIPs, attack) //肉鸡检测
 
+	input := DetectionInput{Flows: flows, Attack: attack, Start: startTime}
+
 	var events []utils.APTEvent
-	for _, detect := range detections {
-		if result := detect(flows); result.Triggered {
+	for _, detector := range a.registry.active(ScopeVictim, a.overrides) {
+		if result := detector.Detect(a, input); result.Triggered {
 			events = append(events, utils.APTEvent{
 				StartTime:     startTime,
 				EndTime:       endTime,
 				SourceIP:      attack.DestIP,
 				DestIP:        "",
+				Detector:      detector.Name(),
 				EventName:     result.EventName,
 				EventType:     result.EventType,
 				SeverityLevel: result.SeverityLevel,
 				Description:   result.Description,
+				Metadata:      result.Metadata,
+				Evidence:      detectionEvidence(attack, result),
 			})
 		}
 	}
@@ -188,16 +234,18 @@ func (a *NAAnalyzer) detectVictimOutbound(flows []utils.TcpLog) DetectionResult
 	}
 
 	clientIP := flows[0].ClientIP
+	t := a.thresholdsFor(clientIP)
 	baseline := a.getConnectionBaseline(clientIP)
-	currentRate := len(flows) / int(postAttackWindow.Hours())
+	currentRate := len(flows) / int(t.PostAttackWindow.Hours())
 
-	if currentRate > baseline*3 {
+	if currentRate > baseline*t.OutboundSpikeFactor {
 		return DetectionResult{
 			Triggered:     true,
 			EventName:     EventReverseConnection,
 			EventType:     PhaseC2,
 			Description:   fmt.Sprintf("外联频率异常: 当前%d次/小时 (基线%d)", currentRate, baseline),
 			SeverityLevel: 4,
+			FlowIDs:       flowIDs(flows),
 		}
 	}
 	return DetectionResult{Triggered: false}
@@ -205,18 +253,23 @@ func (a *NAAnalyzer) detectVictimOutbound(flows []utils.TcpLog) DetectionResult
 
 // 检测规则2：数据渗出检测
 func (a *NAAnalyzer) detectDataExfiltration(flows []utils.TcpLog) DetectionResult {
-	totalSent := 0
+	var totalSent int64
+	var senders []utils.TcpLog
 	for _, f := range flows {
-		totalSent += int(f.UpBytes)
+		totalSent += f.UpBytes
+		if f.UpBytes > 0 {
+			senders = append(senders, f)
+		}
 	}
 
-	if totalSent > 100*1024*1024 {
+	if totalSent > a.flowThresholds(flows).ExfilBytes {
 		return DetectionResult{
 			Triggered:     true,
 			EventName:     EventDataTransfer,
 			EventType:     PhaseDataExfiltration,
 			Description:   fmt.Sprintf("异常数据外传: %.2f MB", float64(totalSent)/1024/1024),
 			SeverityLevel: 5,
+			FlowIDs:       flowIDs(senders),
 		}
 	}
 	return DetectionResult{Triggered: false}
@@ -224,19 +277,22 @@ func (a *NAAnalyzer) detectDataExfiltration(flows []utils.TcpLog) DetectionResul
 
 // 检测规则3：恶意服务器连接
 func (a *NAAnalyzer) detectMaliciousConnections(flows []utils.TcpLog) DetectionResult {
-	if !maliciousIPCheck {
+	t := a.flowThresholds(flows)
+	if !t.MaliciousIPCheck {
 		return DetectionResult{Triggered: false}
 	}
 
 	maliciousSet := make(map[string]struct{})
-	for _, ip := range maliciousIPs {
+	for _, ip := range t.MaliciousIPs {
 		maliciousSet[ip] = struct{}{}
 	}
 
 	var hits []string
+	var ids []uint
 	for _, f := range flows {
 		if _, exists := maliciousSet[f.ServerIP]; exists {
 			hits = append(hits, f.ServerIP)
+			ids = append(ids, f.ID)
 		}
 	}
 
@@ -247,6 +303,7 @@ func (a *NAAnalyzer) detectMaliciousConnections(flows []utils.TcpLog) DetectionR
 			EventType:     PhaseInitialAccess,
 			Description:   fmt.Sprintf("连接已知恶意IP: %v", hits),
 			SeverityLevel: 5, // 最高级别
+			FlowIDs:       ids,
 		}
 	}
 	return DetectionResult{Triggered: false}
@@ -254,7 +311,7 @@ func (a *NAAnalyzer) detectMaliciousConnections(flows []utils.TcpLog) DetectionR
 
 // 检测规则4：C2通信检测
 func (a *NAAnalyzer) detectC2Communication(flows []utils.TcpLog) DetectionResult {
-	const c2Threshold = 60 * 60 // 1小时持续通信
+	c2Threshold := a.flowThresholds(flows).C2Duration.Seconds() // 持续通信时长
 	var suspects []utils.TcpLog
 
 	for _, f := range flows {
@@ -274,6 +331,7 @@ func (a *NAAnalyzer) detectC2Communication(flows []utils.TcpLog) DetectionResult
 			EventType:     eventTypeMapping["LongConnection"],
 			Description:   desc,
 			SeverityLevel: 4,
+			FlowIDs:       flowIDs(suspects),
 		}
 	}
 	return DetectionResult{Triggered: false}
@@ -281,47 +339,62 @@ func (a *NAAnalyzer) detectC2Communication(flows []utils.TcpLog) DetectionResult
 
 // 检测规则1：高频连接
 func (a *NAAnalyzer) detectConnectionFrequency(flows []utils.TcpLog) DetectionResult {
-	hourlyConn := make(map[int]int)
+	threshold := a.flowThresholds(flows).FreqThreshold
+	var hourlyConn [24][]uint
 	for _, f := range flows {
 		hour := f.StartTime.Hour()
-		hourlyConn[hour]++
+		hourlyConn[hour] = append(hourlyConn[hour], f.ID)
 	}
 
-	for h, cnt := range hourlyConn {
-		if cnt > freqThreshold {
-			return DetectionResult{
-				Triggered:     true,
-				EventName:     EventBruteForce,
-				EventType:     PhaseInitialAccess,
-				Description:   fmt.Sprintf("异常连接频率: %d次/小时 (时段%d:00)", cnt, h),
-				SeverityLevel: 3,
-			}
+	// 取连接最多的时段，保证同一批会话的证据稳定
+	busiest := 0
+	for h := range hourlyConn {
+		if len(hourlyConn[h]) > len(hourlyConn[busiest]) {
+			busiest = h
+		}
+	}
+	if cnt := len(hourlyConn[busiest]); cnt > threshold {
+		return DetectionResult{
+			Triggered:     true,
+			EventName:     EventBruteForce,
+			EventType:     PhaseInitialAccess,
+			Description:   fmt.Sprintf("异常连接频率: %d次/小时 (时段%d:00)", cnt, busiest),
+			SeverityLevel: 3,
+			FlowIDs:       hourlyConn[busiest],
 		}
 	}
 	return DetectionResult{Triggered: false}
 }
 
-// 检测规则2：新IP连接
-func (a *NAAnalyzer) detectNewIPConnections(flows []utils.TcpLog) DetectionResult {
-	ipMap := make(map[string]struct{})
+// 检测规则2：新IP连接，历史截止到检测窗口起点
+func (a *NAAnalyzer) detectNewIPConnections(in DetectionInput) DetectionResult {
+	flows := in.Flows
+	ipMap := make(map[string]bool) // 服务端IP -> 是否为新IP
 	var newIPs []string
+	var ids []uint
 
 	for _, f := range flows {
-		if _, exists := ipMap[f.ServerIP]; !exists {
-			if !a.checkIPHistorical(f.ClientIP, f.ServerIP) {
+		isNew, exists := ipMap[f.ServerIP]
+		if !exists {
+			isNew = !a.checkIPHistorical(f.ClientIP, f.ServerIP, in.Start)
+			if isNew {
 				newIPs = append(newIPs, f.ServerIP)
 			}
-			ipMap[f.ServerIP] = struct{}{}
+			ipMap[f.ServerIP] = isNew
+		}
+		if isNew {
+			ids = append(ids, f.ID)
 		}
 	}
 
-	if len(newIPs) >= newIPThreshold {
+	if len(newIPs) >= a.flowThresholds(flows).NewIPThreshold {
 		return DetectionResult{
 			Triggered:     true,
 			EventName:     EventNewConnection,
 			EventType:     PhaseInitialAccess,
 			Description:   fmt.Sprintf("发现%d个新IP连接: %v", len(newIPs), newIPs),
 			SeverityLevel: 2,
+			FlowIDs:       ids,
 		}
 	}
 	return DetectionResult{Triggered: false}
@@ -329,25 +402,35 @@ func (a *NAAnalyzer) detectNewIPConnections(flows []utils.TcpLog) DetectionResul
 
 // 检测规则3：端口扫描
 func (a *NAAnalyzer) detectPortScanPattern(flows []utils.TcpLog) DetectionResult {
+	t := a.flowThresholds(flows)
 	portCounter := make(map[int]int)
 	for _, f := range flows {
 		portCounter[f.ServerPort]++
 	}
 
 	var suspiciousPorts []int
+	suspicious := make(map[int]bool)
 	for port, cnt := range portCounter {
-		if cnt > 3 {
+		if cnt > t.ScanPortHits {
 			suspiciousPorts = append(suspiciousPorts, port)
+			suspicious[port] = true
 		}
 	}
 
-	if len(suspiciousPorts) > 5 {
+	if len(suspiciousPorts) > t.ScanPortCount {
+		var ids []uint
+		for _, f := range flows {
+			if suspicious[f.ServerPort] {
+				ids = append(ids, f.ID)
+			}
+		}
 		return DetectionResult{
 			Triggered:     true,
 			EventName:     EventPortScan,
-			EventType:     PhaseLateralMovement,
+			EventType:     PhaseInitialAccess,
 			Description:   fmt.Sprintf("疑似端口扫描，涉及%d个端口", len(suspiciousPorts)),
 			SeverityLevel: 4,
+			FlowIDs:       ids,
 		}
 	}
 	return DetectionResult{Triggered: false}
@@ -362,9 +445,11 @@ func (a *NAAnalyzer) detectProtocolAnomalies(flows []utils.TcpLog) DetectionResu
 	}
 
 	anomalyProtocols := make(map[int]int)
+	var ids []uint
 	for _, f := range flows {
 		if _, ok := validProtocols[f.Protocol]; !ok {
 			anomalyProtocols[f.Protocol]++
+			ids = append(ids, f.ID)
 		}
 	}
 
@@ -379,6 +464,7 @@ func (a *NAAnalyzer) detectProtocolAnomalies(flows []utils.TcpLog) DetectionResu
 			EventType:     PhaseLateralMovement,
 			Description:   desc,
 			SeverityLevel: 3,
+			FlowIDs:       ids,
 		}
 	}
 	return DetectionResult{Triggered: false}
@@ -401,6 +487,11 @@ func (a *NAAnalyzer) collectZombieIPs(flows []utils.TcpLog) []string {
 
 // 肉鸡行为分析
 func (a *NAAnalyzer) analyzeZombies(zombieIPs []string, attack utils.AttackLog) {
+	detectors := a.registry.active(ScopeZombie, a.overrides)
+	if len(detectors) == 0 {
+		return
+	}
+
 	var wg sync.WaitGroup
 	sem := make(chan struct{}, 10) // 并发控制
 
@@ -416,39 +507,32 @@ func (a *NAAnalyzer) analyzeZombies(zombieIPs []string, attack utils.AttackLog)
 
 			// 分析时间窗口：攻击发生后的时间段
 			startTime := attack.LogTime
-			endTime := startTime.Add(zombieWindow)
+			endTime := startTime.Add(a.thresholdsFor(ip).ZombieWindow)
 
-			var flows []utils.TcpLog
-			a.db.Where("client_ip = ? AND start_time BETWEEN ? AND ?",
-				ip,
-				startTime,
-				endTime,
-			).Find(&flows)
+			flows := a.loadFlows(ip, startTime, endTime)
 
 			if len(flows) == 0 {
 				return
 			}
 
 			// 执行肉鸡检测规则
-			detections := []func([]utils.TcpLog, string) DetectionResult{
-				a.detectZombieNewConnections,
-				a.detectZombieReverseConn,
-				a.detectZombieActivitySpike,
-				a.detectZombieMaliciousConn,
-			}
+			input := DetectionInput{Flows: flows, Attack: attack, Start: startTime}
 
 			var events []utils.APTEvent
-			for _, detect := range detections {
-				if result := detect(flows, attack.SourceIP); result.Triggered {
+			for _, detector := range detectors {
+				if result := detector.Detect(a, input); result.Triggered {
 					events = append(events, utils.APTEvent{
 						StartTime:     startTime,
 						EndTime:       endTime,
 						SourceIP:      ip,
 						DestIP:        "",
+						Detector:      detector.Name(),
 						EventName:     result.EventName,
 						EventType:     "ZOMBIE_" + result.EventType,
 						SeverityLevel: result.SeverityLevel + 1, // 提高严重级别
 						Description:   result.Description,
+						Metadata:      result.Metadata,
+						Evidence:      detectionEvidence(attack, result),
 					})
 				}
 			}
@@ -466,24 +550,28 @@ func (a *NAAnalyzer) detectZombieNewConnections(flows []utils.TcpLog, attackerIP
 	}
 	historicalIPs := a.getHistoricalConnections(flows[0].ClientIP)
 	var newIPs []string
+	var ids []uint
 
 	ipMap := make(map[string]struct{})
 	for _, f := range flows {
+		if historicalIPs[f.ServerIP] {
+			continue
+		}
+		ids = append(ids, f.ID)
 		if _, exists := ipMap[f.ServerIP]; !exists {
-			if !historicalIPs[f.ServerIP] {
-				newIPs = append(newIPs, f.ServerIP)
-			}
+			newIPs = append(newIPs, f.ServerIP)
 			ipMap[f.ServerIP] = struct{}{}
 		}
 	}
 
-	if len(newIPs) >= zombieNewIPThreshold {
+	if len(newIPs) >= a.flowThresholds(flows).ZombieNewIPThreshold {
 		return DetectionResult{
 			Triggered:     true,
 			EventName:     EventNewConnection,
 			EventType:     PhaseInitialAccess,
 			Description:   fmt.Sprintf("连接%d个新IP: %v", len(newIPs), newIPs),
 			SeverityLevel: 4,
+			FlowIDs:       ids,
 		}
 	}
 	return DetectionResult{Triggered: false}
@@ -492,20 +580,21 @@ func (a *NAAnalyzer) detectZombieNewConnections(flows []utils.TcpLog, attackerIP
 // 肉鸡检测规则2：反向连接攻击者
 func (a *NAAnalyzer) detectZombieReverseConn(flows []utils.TcpLog, attackerIP string) DetectionResult {
 
-	var reverseConns int
+	var ids []uint
 	for _, f := range flows {
 		if f.ServerIP == attackerIP {
-			reverseConns++
+			ids = append(ids, f.ID)
 		}
 	}
 
-	if reverseConns > 3 {
+	if reverseConns := len(ids); reverseConns > a.flowThresholds(flows).ZombieReverseConns {
 		return DetectionResult{
 			Triggered:     true,
 			EventName:     "ZOMBIE_ReverseConnection",
 			EventType:     PhaseC2,
 			Description:   fmt.Sprintf("主动连接攻击者IP %s %d次", attackerIP, reverseConns),
 			SeverityLevel: 5,
+			FlowIDs:       ids,
 		}
 	}
 	return DetectionResult{Triggered: false}
@@ -516,16 +605,22 @@ func (a *NAAnalyzer) detectZombieActivitySpike(flows []utils.TcpLog, _ string) D
 	if len(flows) == 0 {
 		return DetectionResult{Triggered: false}
 	}
+	t := a.flowThresholds(flows)
+	// 窗口内连接总数不足时不视为激增，避免无基线的低频主机误报
+	if len(flows) < t.ZombieConnThreshold {
+		return DetectionResult{Triggered: false}
+	}
 	baseline := a.getConnectionBaseline(flows[0].ClientIP)
-	current := len(flows) / int(zombieWindow.Hours())
+	current := len(flows) / int(t.ZombieWindow.Hours())
 
-	if current > baseline*5 {
+	if current > baseline*t.ZombieSpikeFactor {
 		return DetectionResult{
 			Triggered:     true,
 			EventName:     "ZOMBIE_ACTIVITY_SPIKE",
 			EventType:     PhaseC2,
 			Description:   fmt.Sprintf("连接频率异常: %d次/小时 (基线%d)", current, baseline),
 			SeverityLevel: 4,
+			FlowIDs:       flowIDs(flows),
 		}
 	}
 	return DetectionResult{Triggered: false}
@@ -533,29 +628,31 @@ func (a *NAAnalyzer) detectZombieActivitySpike(flows []utils.TcpLog, _ string) D
 
 // 肉鸡检测规则4：恶意连接
 func (a *NAAnalyzer) detectZombieMaliciousConn(flows []utils.TcpLog, _ string) DetectionResult {
-	if !maliciousIPCheck {
+	t := a.flowThresholds(flows)
+	if !t.MaliciousIPCheck {
 		return DetectionResult{Triggered: false}
 	}
 
 	maliciousSet := make(map[string]struct{})
-	for _, ip := range maliciousIPs {
+	for _, ip := range t.MaliciousIPs {
 		maliciousSet[ip] = struct{}{}
 	}
 
-	var hits int
+	var ids []uint
 	for _, f := range flows {
 		if _, exists := maliciousSet[f.ServerIP]; exists {
-			hits++
+			ids = append(ids, f.ID)
 		}
 	}
 
-	if hits > 0 {
+	if hits := len(ids); hits > 0 {
 		return DetectionResult{
 			Triggered:     true,
 			EventName:     "ZOMBIE_MALICIOUS_CONN",
 			EventType:     PhaseC2,
 			Description:   fmt.Sprintf("连接%d次已知恶意IP", hits),
 			SeverityLevel: 5,
+			FlowIDs:       ids,
 		}
 	}
 	return DetectionResult{Triggered: false}
@@ -578,20 +675,20 @@ func (a *NAAnalyzer) getHistoricalConnections(ip string) map[string]bool {
 	return connections
 }
 
-// 获取IP历史连接画像
-func (a *NAAnalyzer) checkIPHistorical(clientIP, serverIP string) bool {
-	if profile, exists := a.ipProfiles.Load(clientIP); exists {
+// 获取IP历史连接画像，历史截止到被分析事件的开始时间，与分析运行的时间无关
+func (a *NAAnalyzer) checkIPHistorical(clientIP, serverIP string, before time.Time) bool {
+	if profile, exists := a.ipProfiles.Load(clientIP); exists && profile.(*IPProfile).Before.Equal(before) {
 		return profile.(*IPProfile).HasConnected(serverIP)
 	}
 
-	// 首次查询建立画像
-	var history []utils.TcpLog
-	a.db.Where("client_ip = ? AND start_time < ?",
-		clientIP,
-		time.Now().Add(-24*time.Hour),
-	).Find(&history)
+	// 首次查询或截止时间不同时重建画像
+	history, err := a.flows.FlowsBefore(a.caseID, clientIP, before)
+	if err != nil {
+		log.Printf("历史会话查询失败: %v", err)
+	}
 
 	profile := NewIPProfile(clientIP)
+	profile.Before = before
 	for _, f := range history {
 		profile.AddConnection(f.ServerIP)
 	}
@@ -608,21 +705,37 @@ func (a *NAAnalyzer) getConnectionBaseline(ip string) int {
 	return 10 // 默认基线值
 }
 
+// 事件证据：触发分析的攻击日志及规则命中的会话
+func detectionEvidence(attack utils.AttackLog, result DetectionResult) []utils.EventEvidence {
+	evidence := make([]utils.EventEvidence, 0, len(result.FlowIDs)+1)
+	evidence = append(evidence, utils.EventEvidence{LogType: utils.EvidenceAttack, LogID: attack.ID})
+	for _, id := range result.FlowIDs {
+		evidence = append(evidence, utils.EventEvidence{LogType: utils.EvidenceTcp, LogID: id})
+	}
+	return evidence
+}
+
+// 会话ID列表
+func flowIDs(flows []utils.TcpLog) []uint {
+	ids := make([]uint, len(flows))
+	for i, f := range flows {
+		ids[i] = f.ID
+	}
+	return ids
+}
+
 func (a *NAAnalyzer) saveEvents(events []utils.APTEvent) {
 	if len(events) == 0 {
 		return
 	}
 
-	tx := a.db.Begin()
-	for _, e := range events {
-		e.Model.UpdatedAt = time.Now()
-		if err := tx.Create(&e).Error; err != nil {
-			tx.Rollback()
-			log.Printf("事件保存失败: %v", err)
-			return
-		}
+	for i := range events {
+		events[i].CaseID = a.caseID
+		events[i].Fingerprint = events[i].ComputeFingerprint()
+	}
+	if err := a.events.SaveEvents(events); err != nil {
+		log.Printf("事件保存失败: %v", err)
 	}
-	tx.Commit()
 }
 
 func NewIPProfile(ip string) *IPProfile {
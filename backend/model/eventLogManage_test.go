@@ -0,0 +1,59 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"awesomeProject1/backend/utils"
+)
+
+func TestDetectZombieActivitySpike(t *testing.T) {
+	th := DefaultThresholds()
+	th.ZombieWindow = time.Hour
+	th.ZombieSpikeFactor = 1
+	th.ZombieConnThreshold = 100
+	a := NewAnalyzer(nil, nil, 1)
+	a.thresholds = &ThresholdConfig{Default: th}
+
+	tests := []struct {
+		name  string
+		conns int
+		want  bool
+	}{
+		{"超过基线但连接数不足", 50, false},
+		{"达到连接数阈值", 100, true},
+		{"低于基线", 5, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flows := make([]utils.TcpLog, tt.conns)
+			for i := range flows {
+				flows[i] = utils.TcpLog{ID: uint(i + 1), ClientIP: "10.0.0.5", ServerIP: "203.0.113.7"}
+			}
+			if got := a.detectZombieActivitySpike(flows, "").Triggered; got != tt.want {
+				t.Errorf("detectZombieActivitySpike() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectPortScanIsReconnaissance(t *testing.T) {
+	th := DefaultThresholds()
+	th.ScanPortHits = 0
+	th.ScanPortCount = 2
+	a := NewAnalyzer(nil, nil, 1)
+	a.thresholds = &ThresholdConfig{Default: th}
+
+	var flows []utils.TcpLog
+	for port := 20; port < 25; port++ {
+		flows = append(flows, utils.TcpLog{ID: uint(port), ClientIP: "10.0.0.5", ServerIP: "10.0.0.9", ServerPort: port})
+	}
+	result := a.detectPortScanPattern(flows)
+	if !result.Triggered {
+		t.Fatal("detectPortScanPattern() 未触发")
+	}
+	// 端口扫描属于侦察，归入初始访问阶段而非横向移动
+	if result.EventType != PhaseInitialAccess || eventTypeMapping[EventPortScan] != PhaseInitialAccess {
+		t.Errorf("端口扫描阶段 = %s / %s, want %s", result.EventType, eventTypeMapping[EventPortScan], PhaseInitialAccess)
+	}
+}
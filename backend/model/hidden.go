@@ -0,0 +1,68 @@
+package model
+
+import (
+	"awesomeProject1/backend/utils"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const hiddenAttackDetector = "hidden_attack"
+
+// 隐藏攻击发现：攻击日志并不完整，已知攻击者对目标服务的会话前后均无该攻击者对该目标的攻击日志时，
+// 视为攻击日志未记录的攻击会话
+func (a *NAAnalyzer) detectHiddenAttack(in DetectionInput) DetectionResult {
+	attacker, target := in.Attack.SourceIP, in.Attack.DestIP
+	tolerance := a.thresholdsFor(attacker).HiddenMatchWindow
+	recorded := a.recorded[attacker][target]
+
+	var ids []uint
+	var bytes int64
+	var first, last time.Time
+	for _, f := range in.Flows {
+		if attackRecorded(recorded, f, tolerance) {
+			continue
+		}
+		if len(ids) == 0 {
+			first = f.StartTime
+		}
+		ids = append(ids, f.ID)
+		bytes += f.UpBytes + f.DownBytes
+		last = f.StartTime
+	}
+	if len(ids) == 0 {
+		return DetectionResult{Triggered: false}
+	}
+
+	port := in.Flows[0].ServerPort
+	metadata, _ := json.Marshal(map[string]interface{}{
+		"attacker":  attacker,
+		"target":    target,
+		"port":      port,
+		"sessions":  len(ids),
+		"bytes":     bytes, // 上下行字节数之和
+		"firstSeen": first,
+		"lastSeen":  last,
+	})
+	return DetectionResult{
+		Triggered:     true,
+		EventName:     EventHiddenAttack,
+		EventType:     PhaseInitialAccess,
+		Description:   fmt.Sprintf("攻击日志未记录的会话: 已知攻击者访问%s:%d共%d次", target, port, len(ids)),
+		SeverityLevel: 3,
+		FlowIDs:       ids,
+		Metadata:      metadata,
+	}
+}
+
+// 会话前后tolerance内是否有同一攻击者对该目标的攻击日志
+func attackRecorded(times []time.Time, f utils.TcpLog, tolerance time.Duration) bool {
+	start := f.StartTime.Add(-tolerance)
+	end := flowEnd(f).Add(tolerance)
+	for _, t := range times {
+		if !t.Before(start) && !t.After(end) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,184 @@
+package model
+
+import (
+	"awesomeProject1/backend/utils"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 木马载荷：攻击者相关IP与受害者之间的大流量传输
+type implantPayload struct {
+	Peer     string    `json:"peer"` // 载荷来源
+	Port     int       `json:"port"`
+	Bytes    int64     `json:"bytes"`
+	Baseline int64     `json:"baseline"` // 受害者受攻击前的下载量中位数
+	Time     time.Time `json:"time"`     // 传输完成时间
+	start    time.Time
+	flowID   uint
+}
+
+// 载荷送达后新出现的回连
+type implantCallback struct {
+	Server      string    `json:"server"`
+	Port        int       `json:"port"`
+	FirstSeen   time.Time `json:"firstSeen"`
+	Connections int       `json:"connections"`
+	Persistent  bool      `json:"persistent"`              // 存在持久连接
+	Period      float64   `json:"periodSeconds,omitempty"` // 周期回连的估计周期（秒）
+	flowIDs     []uint
+}
+
+// 检测规则8：木马植入
+// 受害者从攻击者相关IP接收远超平时的载荷后，短时间内出现新的持久或周期性外联
+func (a *NAAnalyzer) detectTrojanImplant(in DetectionInput) DetectionResult {
+	victim := in.Attack.DestIP
+	t := a.thresholdsFor(victim)
+
+	suspects := map[string]bool{in.Attack.SourceIP: true}
+	for _, ip := range t.MaliciousIPs {
+		suspects[ip] = true
+	}
+
+	// 以受攻击前的下载量为基线，避免载荷本身抬高基线
+	history, err := a.flows.FlowsBefore(a.caseID, victim, in.Attack.LogTime)
+	if err != nil {
+		log.Printf("历史会话查询失败: %v", err)
+		return DetectionResult{Triggered: false}
+	}
+	downloads := make([]float64, len(history))
+	for i, f := range history {
+		downloads[i] = float64(f.DownBytes)
+	}
+	baseline := int64(median(downloads))
+	threshold := max(t.ImplantPayloadBytes, baseline*int64(t.ImplantSpikeFactor))
+
+	var payloads []implantPayload
+	// 受害者从攻击者相关IP下载
+	for _, f := range in.Flows {
+		if suspects[f.ServerIP] && f.DownBytes >= threshold {
+			payloads = append(payloads, implantPayload{Peer: f.ServerIP, Port: f.ServerPort, Bytes: f.DownBytes,
+				Baseline: baseline, Time: flowEnd(f), start: f.StartTime, flowID: f.ID})
+		}
+	}
+	// 攻击者向受害者推送
+	pushed := a.loadFlows(in.Attack.SourceIP, in.Attack.LogTime, in.Attack.LogTime.Add(t.PostAttackWindow))
+	for _, f := range pushed {
+		if f.ServerIP == victim && f.UpBytes >= threshold {
+			payloads = append(payloads, implantPayload{Peer: f.ClientIP, Port: f.ServerPort, Bytes: f.UpBytes,
+				Baseline: baseline, Time: flowEnd(f), start: f.StartTime, flowID: f.ID})
+		}
+	}
+	if len(payloads) == 0 {
+		return DetectionResult{Triggered: false}
+	}
+	sort.Slice(payloads, func(i, j int) bool { return payloads[i].Time.Before(payloads[j].Time) })
+
+	for _, p := range payloads {
+		callbacks := implantCallbacks(in.Flows, history, p, t)
+		if len(callbacks) == 0 {
+			continue
+		}
+
+		ids := []uint{p.flowID}
+		parts := make([]string, 0, len(callbacks))
+		for _, c := range callbacks {
+			ids = append(ids, c.flowIDs...)
+			kind := "持久连接"
+			if c.Period > 0 {
+				kind = fmt.Sprintf("周期%s", time.Duration(c.Period*float64(time.Second)).Round(time.Second))
+			}
+			parts = append(parts, fmt.Sprintf("%s:%d(%s)", c.Server, c.Port, kind))
+		}
+		metadata, _ := json.Marshal(map[string]interface{}{"payload": p, "callbacks": callbacks})
+
+		desc := fmt.Sprintf("疑似木马植入: 自%s:%d传输%.2fMB载荷(历史下载量中位数%.2fMB)，%.0f分钟内新增回连 %s",
+			p.Peer, p.Port, float64(p.Bytes)/1024/1024, float64(p.Baseline)/1024/1024,
+			t.ImplantCallback.Minutes(), strings.Join(parts, " "))
+		return DetectionResult{
+			Triggered:     true,
+			EventName:     EventTrojanImplant,
+			EventType:     PhasePersistence,
+			Description:   desc,
+			SeverityLevel: 5,
+			FlowIDs:       ids,
+			Metadata:      metadata,
+		}
+	}
+	return DetectionResult{Triggered: false}
+}
+
+// 载荷送达后回连窗口内首次出现的外网服务端，且为持久连接或周期性连接
+// 内网目标由横向移动规则处理
+func implantCallbacks(flows, history []utils.TcpLog, p implantPayload, t Thresholds) []*implantCallback {
+	networks := parseNetworks(t.InternalNetworks)
+	endpoint := func(f utils.TcpLog) string { return fmt.Sprintf("%s:%d", f.ServerIP, f.ServerPort) }
+
+	// 载荷传输开始前访问过的服务端视为常规访问
+	known := make(map[string]bool)
+	for _, f := range history {
+		known[endpoint(f)] = true
+	}
+	for _, f := range flows {
+		if f.StartTime.Before(p.start) || f.ID == p.flowID {
+			known[endpoint(f)] = true
+		}
+	}
+
+	sorted := append([]utils.TcpLog(nil), flows...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].StartTime.Before(sorted[j].StartTime) })
+
+	deadline := p.Time.Add(t.ImplantCallback)
+	sessions := make(map[string][]utils.TcpLog)
+	var order []string
+	for _, f := range sorted {
+		key := endpoint(f)
+		if known[key] || f.StartTime.Before(p.Time) || inNetworks(networks, f.ServerIP) {
+			continue
+		}
+		if _, exists := sessions[key]; !exists {
+			if f.StartTime.After(deadline) {
+				continue
+			}
+			order = append(order, key)
+		}
+		sessions[key] = append(sessions[key], f)
+	}
+
+	var callbacks []*implantCallback
+	for _, key := range order {
+		members := sessions[key]
+		c := &implantCallback{
+			Server:      members[0].ServerIP,
+			Port:        members[0].ServerPort,
+			FirstSeen:   members[0].StartTime,
+			Connections: len(members),
+			flowIDs:     flowIDs(members),
+		}
+		for _, f := range members {
+			if f.Duration >= t.ImplantPersist.Seconds() {
+				c.Persistent = true
+			}
+		}
+		if len(members) >= t.BeaconMinConns {
+			if s, ok := analyzeBeacon(append([]utils.TcpLog(nil), members...), t); ok {
+				c.Period = s.Period
+			}
+		}
+		if c.Persistent || c.Period > 0 {
+			callbacks = append(callbacks, c)
+		}
+	}
+	return callbacks
+}
+
+// 会话结束时间，缺失时取开始时间
+func flowEnd(f utils.TcpLog) time.Time {
+	if f.EndTime.After(f.StartTime) {
+		return f.EndTime
+	}
+	return f.StartTime
+}
@@ -0,0 +1,154 @@
+package model
+
+import (
+	"awesomeProject1/backend/utils"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 横向移动中首次连接的内网主机
+type lateralPeer struct {
+	IP          string    `json:"ip"`
+	Ports       []int     `json:"ports"`
+	Connections int       `json:"connections"`
+	FirstSeen   time.Time `json:"firstSeen"`
+}
+
+// 检测规则6：内网横向移动
+// 内网主机成为攻击目标后的观察窗口内，首次连接其他内网主机的管理服务（SMB、RDP、WinRM、SSH、RPC）
+func (a *NAAnalyzer) detectLateralMovement(in DetectionInput) DetectionResult {
+	host := in.Attack.DestIP
+	t := a.thresholdsFor(host)
+	networks := parseNetworks(t.InternalNetworks)
+	if !inNetworks(networks, host) {
+		return DetectionResult{Triggered: false}
+	}
+
+	adminPorts := make(map[int]bool, len(t.LateralPorts))
+	for _, port := range t.LateralPorts {
+		adminPorts[port] = true
+	}
+
+	deadline := in.Attack.LogTime.Add(t.LateralWindow)
+	var candidates []utils.TcpLog
+	for _, f := range in.Flows {
+		if f.StartTime.Before(in.Attack.LogTime) || f.StartTime.After(deadline) {
+			continue
+		}
+		if !adminPorts[f.ServerPort] || f.ServerIP == host || f.ServerIP == in.Attack.SourceIP {
+			continue
+		}
+		if inNetworks(networks, f.ServerIP) {
+			candidates = append(candidates, f)
+		}
+	}
+	if len(candidates) == 0 {
+		return DetectionResult{Triggered: false}
+	}
+
+	// 受攻击前连接过的主机视为常规访问
+	history, err := a.flows.FlowsBefore(a.caseID, host, in.Attack.LogTime)
+	if err != nil {
+		log.Printf("历史会话查询失败: %v", err)
+		return DetectionResult{Triggered: false}
+	}
+	known := make(map[string]bool)
+	for _, f := range history {
+		known[f.ServerIP] = true
+	}
+
+	peers := make(map[string]*lateralPeer)
+	var ids []uint
+	for _, f := range candidates {
+		if known[f.ServerIP] {
+			continue
+		}
+		p, exists := peers[f.ServerIP]
+		if !exists {
+			p = &lateralPeer{IP: f.ServerIP, FirstSeen: f.StartTime}
+			peers[f.ServerIP] = p
+		}
+		if !containsPort(p.Ports, f.ServerPort) {
+			p.Ports = append(p.Ports, f.ServerPort)
+		}
+		if f.StartTime.Before(p.FirstSeen) {
+			p.FirstSeen = f.StartTime
+		}
+		p.Connections++
+		ids = append(ids, f.ID)
+	}
+	if len(peers) < t.LateralNewPeers {
+		return DetectionResult{Triggered: false}
+	}
+
+	list := make([]lateralPeer, 0, len(peers))
+	for _, p := range peers {
+		sort.Ints(p.Ports)
+		list = append(list, *p)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].FirstSeen.Before(list[j].FirstSeen) ||
+			(list[i].FirstSeen.Equal(list[j].FirstSeen) && list[i].IP < list[j].IP)
+	})
+
+	parts := make([]string, 0, len(list))
+	for _, p := range list {
+		parts = append(parts, fmt.Sprintf("%s%v", p.IP, p.Ports))
+	}
+	metadata, _ := json.Marshal(map[string]interface{}{
+		"host":       host,
+		"attacker":   in.Attack.SourceIP,
+		"attackTime": in.Attack.LogTime,
+		"peers":      list,
+	})
+
+	desc := fmt.Sprintf("受攻击后%.0f分钟内首次连接%d台内网主机的管理服务: %s",
+		t.LateralWindow.Minutes(), len(list), strings.Join(parts, " "))
+	return DetectionResult{
+		Triggered:     true,
+		EventName:     EventLateralMovement,
+		EventType:     PhaseLateralMovement,
+		Description:   desc,
+		SeverityLevel: 4,
+		FlowIDs:       ids,
+		Metadata:      metadata,
+	}
+}
+
+// 解析网段列表，格式错误的网段已在加载阈值时拒绝
+func parseNetworks(cidrs []string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if _, network, err := net.ParseCIDR(cidr); err == nil {
+			networks = append(networks, network)
+		}
+	}
+	return networks
+}
+
+func inNetworks(networks []*net.IPNet, ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsPort(ports []int, port int) bool {
+	for _, p := range ports {
+		if p == port {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+package model
+
+import (
+	"awesomeProject1/backend/utils"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 单个事件最多保留的跳板链数量，避免高扇出主机导致链路组合爆炸
+const maxPivotChains = 20
+
+// 跳板链中的一跳：From在Time向To发起攻击或连接
+type PivotHop struct {
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Time   time.Time `json:"time"`
+	Port   int       `json:"port,omitempty"`
+	Source string    `json:"source"` // 依据的日志类型：attack或tcp
+	LogID  uint      `json:"logId"`
+}
+
+// 跳板链：A→B→C…，相邻两跳首尾相接
+type PivotChain struct {
+	Hops []PivotHop `json:"hops"`
+}
+
+// 链上的主机，按经过顺序排列
+func (c PivotChain) Hosts() []string {
+	if len(c.Hops) == 0 {
+		return nil
+	}
+	hosts := []string{c.Hops[0].From}
+	for _, hop := range c.Hops {
+		hosts = append(hosts, hop.To)
+	}
+	return hosts
+}
+
+func (c PivotChain) String() string {
+	return strings.Join(c.Hosts(), " → ")
+}
+
+// 跳板链事件的结构化信息
+type pivotMetadata struct {
+	Chains []PivotChain `json:"chains"`
+}
+
+// 检测规则7：跳板链
+// 攻击日志A→B之后的时间窗口内，B向其他主机发起攻击或连接内网主机（B→C），逐跳延伸得到完整链路
+func (a *NAAnalyzer) detectPivotChain(in DetectionInput) DetectionResult {
+	first := PivotHop{
+		From:   in.Attack.SourceIP,
+		To:     in.Attack.DestIP,
+		Time:   in.Attack.LogTime,
+		Source: utils.EvidenceAttack,
+		LogID:  in.Attack.ID,
+	}
+	if first.From == "" || first.To == "" || first.From == first.To {
+		return DetectionResult{Triggered: false}
+	}
+
+	t := a.thresholdsFor(first.To)
+	search := &pivotSearch{a: a, t: t, networks: parseNetworks(t.InternalNetworks)}
+	search.extend([]PivotHop{first}, map[string]bool{first.From: true, first.To: true})
+	if len(search.chains) == 0 {
+		return DetectionResult{Triggered: false}
+	}
+
+	chains := search.chains
+	sort.SliceStable(chains, func(i, j int) bool {
+		if len(chains[i].Hops) != len(chains[j].Hops) {
+			return len(chains[i].Hops) > len(chains[j].Hops)
+		}
+		return chains[i].String() < chains[j].String()
+	})
+
+	var ids []uint
+	seen := make(map[uint]bool)
+	parts := make([]string, 0, len(chains))
+	for _, chain := range chains {
+		parts = append(parts, chain.String())
+		for _, hop := range chain.Hops {
+			if hop.Source == utils.EvidenceTcp && !seen[hop.LogID] {
+				seen[hop.LogID] = true
+				ids = append(ids, hop.LogID)
+			}
+		}
+	}
+	metadata, _ := json.Marshal(pivotMetadata{Chains: chains})
+
+	return DetectionResult{
+		Triggered:     true,
+		EventName:     EventPivotChain,
+		EventType:     PhaseLateralMovement,
+		Description:   fmt.Sprintf("发现%d条跳板链: %s", len(chains), strings.Join(parts, "; ")),
+		SeverityLevel: 4,
+		FlowIDs:       ids,
+		Metadata:      metadata,
+	}
+}
+
+// 跳板链深度优先搜索
+type pivotSearch struct {
+	a        *NAAnalyzer
+	t        Thresholds
+	networks []*net.IPNet
+	chains   []PivotChain
+}
+
+// 从path的最后一跳继续延伸，无法延伸时记录至少两跳的链路
+func (s *pivotSearch) extend(path []PivotHop, visited map[string]bool) {
+	if len(s.chains) >= maxPivotChains {
+		return
+	}
+
+	var next []PivotHop
+	if len(path) < s.t.PivotMaxHops {
+		next = s.nextHops(path[len(path)-1], visited)
+	}
+	if len(next) == 0 {
+		if len(path) >= 2 {
+			s.chains = append(s.chains, PivotChain{Hops: append([]PivotHop(nil), path...)})
+		}
+		return
+	}
+
+	for _, hop := range next {
+		visited[hop.To] = true
+		s.extend(append(path, hop), visited)
+		delete(visited, hop.To)
+	}
+}
+
+// 主机被进入后的时间窗口内发起的下一跳，每个目标只保留最早的一跳
+func (s *pivotSearch) nextHops(last PivotHop, visited map[string]bool) []PivotHop {
+	host := last.To
+	end := last.Time.Add(s.t.PivotWindow)
+	hops := make(map[string]PivotHop)
+	add := func(hop PivotHop) {
+		if existing, exists := hops[hop.To]; !exists || hop.Time.Before(existing.Time) {
+			hops[hop.To] = hop
+		}
+	}
+
+	attacks, err := s.a.flows.AttackLogs(s.a.caseID, last.Time, end)
+	if err != nil {
+		log.Printf("攻击日志查询失败: %v", err)
+	}
+	for _, attack := range attacks {
+		if attack.SourceIP != host || visited[attack.DestIP] || attack.DestIP == "" {
+			continue
+		}
+		add(PivotHop{From: host, To: attack.DestIP, Time: attack.LogTime, Source: utils.EvidenceAttack, LogID: attack.ID})
+	}
+
+	// 会话日志仅统计连向内网主机的连接，外网连接由C2与渗出规则处理
+	for _, f := range s.a.loadFlows(host, last.Time, end) {
+		if visited[f.ServerIP] || !inNetworks(s.networks, f.ServerIP) {
+			continue
+		}
+		if _, exists := hops[f.ServerIP]; exists && hops[f.ServerIP].Source == utils.EvidenceAttack {
+			continue
+		}
+		add(PivotHop{From: host, To: f.ServerIP, Time: f.StartTime, Port: f.ServerPort, Source: utils.EvidenceTcp, LogID: f.ID})
+	}
+
+	result := make([]PivotHop, 0, len(hops))
+	for _, hop := range hops {
+		result = append(result, hop)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].Time.Equal(result[j].Time) {
+			return result[i].Time.Before(result[j].Time)
+		}
+		return result[i].To < result[j].To
+	})
+	return result
+}
+
+// 时间窗口内跳板链事件记录的全部链路
+func (tc *TemporalCorrelator) PivotChains(start, end time.Time) ([]PivotChain, error) {
+	events, err := tc.events.ListEvents(tc.caseID, start, end)
+	if err != nil {
+		log.Printf("[错误] 事件查询失败: %v", err)
+		return nil, err
+	}
+
+	var chains []PivotChain
+	for _, event := range events {
+		if event == nil || event.EventName != EventPivotChain || len(event.Metadata) == 0 {
+			continue
+		}
+		var meta pivotMetadata
+		if err := json.Unmarshal(event.Metadata, &meta); err != nil {
+			log.Printf("[警告] 跳板链解析失败 (ID:%d): %v", event.ID, err)
+			continue
+		}
+		chains = append(chains, meta.Chains...)
+	}
+	return chains, nil
+}
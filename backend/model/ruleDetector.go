@@ -0,0 +1,163 @@
+package model
+
+import (
+	"awesomeProject1/backend/rules"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 规则文件中的单条规则定义
+type RuleDefinition struct {
+	Name        string        `yaml:"name" json:"name"`
+	Scope       DetectorScope `yaml:"scope" json:"scope"` // 缺省为attacker
+	Expr        string        `yaml:"expr" json:"expr"`
+	EventName   string        `yaml:"eventName" json:"eventName"`
+	EventType   string        `yaml:"eventType" json:"eventType"` // 攻击阶段
+	Severity    int           `yaml:"severity" json:"severity"`
+	Description string        `yaml:"description" json:"description"`
+}
+
+type ruleDetector struct {
+	def  RuleDefinition
+	rule *rules.Rule
+}
+
+func (d *ruleDetector) Name() string         { return d.def.Name }
+func (d *ruleDetector) Scope() DetectorScope { return d.def.Scope }
+
+func (d *ruleDetector) Description() string {
+	if d.def.Description != "" {
+		return d.def.Description
+	}
+	return d.def.Expr
+}
+
+func (d *ruleDetector) Detect(_ *NAAnalyzer, in DetectionInput) DetectionResult {
+	matches := d.rule.Evaluate(in.Flows)
+	if len(matches) == 0 {
+		return DetectionResult{Triggered: false}
+	}
+
+	desc := fmt.Sprintf("规则%s命中 (%s): ", d.def.Name, d.def.Expr)
+	var ids []uint
+	for _, m := range matches {
+		desc += m.String() + " "
+		ids = append(ids, m.IDs...)
+	}
+	return DetectionResult{
+		Triggered:     true,
+		EventName:     d.def.EventName,
+		EventType:     d.def.EventType,
+		Description:   strings.TrimSpace(desc),
+		SeverityLevel: d.def.Severity,
+		FlowIDs:       ids,
+	}
+}
+
+var knownPhases = map[string]bool{
+	PhaseInitialAccess:    true,
+	PhaseLateralMovement:  true,
+	PhaseC2:               true,
+	PhaseDataExfiltration: true,
+	PhaseDefenseEvasion:   true,
+	PhaseCredentialAccess: true,
+	PhasePersistence:      true,
+}
+
+// 编译规则定义为检测规则
+func NewRuleDetector(def RuleDefinition) (Detector, error) {
+	if def.Name == "" {
+		return nil, fmt.Errorf("规则名称不能为空")
+	}
+	if def.Scope == "" {
+		def.Scope = ScopeAttacker
+	}
+	if def.EventName == "" {
+		def.EventName = def.Name
+	}
+	if !knownPhases[def.EventType] {
+		return nil, fmt.Errorf("规则%s的攻击阶段无效: %q", def.Name, def.EventType)
+	}
+	if def.Severity < 1 || def.Severity > 5 {
+		return nil, fmt.Errorf("规则%s的严重等级需在1-5之间", def.Name)
+	}
+
+	rule, err := rules.Parse(def.Expr)
+	if err != nil {
+		return nil, fmt.Errorf("规则%s表达式错误: %v", def.Name, err)
+	}
+	return &ruleDetector{def: def, rule: rule}, nil
+}
+
+var (
+	ruleFileMu    sync.Mutex
+	ruleFilePath  string
+	loadedRuleSet []string // 由规则文件注册的规则名
+)
+
+// 加载规则文件并注册到默认注册表，重复加载时替换上次加载的规则
+func LoadRuleFile(path string) error {
+	ruleFileMu.Lock()
+	defer ruleFileMu.Unlock()
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		log.Printf("规则文件不存在，跳过: %s", path)
+		ruleFilePath = path
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("规则文件读取失败: %v", err)
+	}
+
+	var file struct {
+		Rules []RuleDefinition `yaml:"rules"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("规则文件解析失败: %v", err)
+	}
+
+	// 先全部编译，避免部分规则错误导致注册表处于中间状态
+	detectors := make([]Detector, 0, len(file.Rules))
+	seen := make(map[string]bool)
+	for _, def := range file.Rules {
+		d, err := NewRuleDetector(def)
+		if err != nil {
+			return err
+		}
+		if seen[d.Name()] {
+			return fmt.Errorf("规则名称重复: %s", d.Name())
+		}
+		seen[d.Name()] = true
+		detectors = append(detectors, d)
+	}
+
+	// 一次性替换上次加载的规则，与内置规则重名等错误时保留原规则集
+	if err := DefaultRegistry.Replace(loadedRuleSet, detectors); err != nil {
+		return fmt.Errorf("规则注册失败: %v", err)
+	}
+	loadedRuleSet = loadedRuleSet[:0]
+	for _, d := range detectors {
+		loadedRuleSet = append(loadedRuleSet, d.Name())
+	}
+
+	ruleFilePath = path
+	log.Printf("规则文件加载成功: %s (规则:%d)", path, len(detectors))
+	return nil
+}
+
+// 重新加载规则文件
+func ReloadRuleFile() error {
+	ruleFileMu.Lock()
+	path := ruleFilePath
+	ruleFileMu.Unlock()
+	if path == "" {
+		return fmt.Errorf("未指定规则文件")
+	}
+	return LoadRuleFile(path)
+}
@@ -0,0 +1,163 @@
+package model
+
+import (
+	"awesomeProject1/backend/utils"
+	"context"
+	"log"
+	"sort"
+	"time"
+)
+
+// 已知攻击者在分析窗口内对单个目标服务的会话
+type serviceGroup struct {
+	target string
+	port   int
+	flows  []utils.TcpLog
+}
+
+// 目标服务分析：攻击日志可能漏报，回查曾在攻击日志中作为攻击源出现的IP在window内发起的全部会话，
+// 按目标服务分组后执行service规则，同一目标服务在窗口内的会话只产生一个事件。事件时间取命中会话的起止时间，
+// 事件指纹按首个命中会话所在时段计算，增量分析时同一时段开始的事件合并
+func (a *NAAnalyzer) analyzeServices(ctx context.Context, window TimeWindow) error {
+	detectors := a.registry.active(ScopeService, a.overrides)
+	if len(detectors) == 0 {
+		return nil
+	}
+	attackers, err := a.loadRecordedAttacks()
+	if err != nil {
+		return err
+	}
+
+	var total int
+	for _, attacker := range attackers {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var events []utils.APTEvent
+		for _, group := range groupServiceFlows(attacker, a.loadFlows(attacker, window.Start, window.End)) {
+			input := DetectionInput{
+				Flows:  group.flows,
+				Attack: utils.AttackLog{CaseID: a.caseID, SourceIP: attacker, DestIP: group.target}, // 无对应攻击日志
+				Start:  window.Start,
+			}
+			for _, detector := range detectors {
+				result := detector.Detect(a, input)
+				if !result.Triggered {
+					continue
+				}
+				start, end := flowSpan(group.flows, result.FlowIDs)
+				events = append(events, utils.APTEvent{
+					StartTime:     start,
+					EndTime:       end,
+					SourceIP:      attacker,
+					DestIP:        group.target,
+					DestPort:      group.port,
+					Detector:      detector.Name(),
+					EventName:     result.EventName,
+					EventType:     result.EventType,
+					SeverityLevel: result.SeverityLevel,
+					Description:   result.Description,
+					Metadata:      result.Metadata,
+					Evidence:      flowEvidence(result.FlowIDs),
+				})
+			}
+		}
+		total += len(events)
+		a.saveEvents(events)
+	}
+	if total > 0 {
+		log.Printf("目标服务分析: %d个已知攻击者，%d个事件 (案件:%d)", len(attackers), total, a.caseID)
+	}
+	return nil
+}
+
+// 加载案件全部攻击日志，建立已知攻击者到目标的攻击时间映射，返回按首次出现排序的攻击者
+func (a *NAAnalyzer) loadRecordedAttacks() ([]string, error) {
+	span, err := a.flows.DataRange(a.caseID)
+	if err != nil {
+		log.Printf("数据范围查询失败: %v", err)
+		return nil, err
+	}
+	attacks, err := a.flows.AttackLogs(a.caseID, span.Start, span.End)
+	if err != nil {
+		log.Printf("攻击日志查询失败: %v", err)
+		return nil, err
+	}
+
+	a.recorded = make(map[string]map[string][]time.Time)
+	var attackers []string
+	for _, attack := range attacks {
+		if attack.SourceIP == "" {
+			continue
+		}
+		targets, exists := a.recorded[attack.SourceIP]
+		if !exists {
+			targets = make(map[string][]time.Time)
+			a.recorded[attack.SourceIP] = targets
+			attackers = append(attackers, attack.SourceIP)
+		}
+		targets[attack.DestIP] = append(targets[attack.DestIP], attack.LogTime)
+	}
+	return attackers, nil
+}
+
+// 按目标IP与端口分组，组内会话按时间排序
+func groupServiceFlows(attacker string, flows []utils.TcpLog) []*serviceGroup {
+	sort.Slice(flows, func(i, j int) bool {
+		if !flows[i].StartTime.Equal(flows[j].StartTime) {
+			return flows[i].StartTime.Before(flows[j].StartTime)
+		}
+		return flows[i].ID < flows[j].ID
+	})
+
+	type groupKey struct {
+		target string
+		port   int
+	}
+	groups := make(map[groupKey]*serviceGroup)
+	var order []*serviceGroup
+	for _, f := range flows {
+		if f.ServerIP == "" || f.ServerIP == attacker {
+			continue
+		}
+		key := groupKey{f.ServerIP, f.ServerPort}
+		g, exists := groups[key]
+		if !exists {
+			g = &serviceGroup{target: key.target, port: key.port}
+			groups[key] = g
+			order = append(order, g)
+		}
+		g.flows = append(g.flows, f)
+	}
+	return order
+}
+
+// 命中会话的最早开始与最晚结束时间
+func flowSpan(flows []utils.TcpLog, ids []uint) (time.Time, time.Time) {
+	hit := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		hit[id] = true
+	}
+	var start, end time.Time
+	for _, f := range flows {
+		if !hit[f.ID] {
+			continue
+		}
+		if start.IsZero() || f.StartTime.Before(start) {
+			start = f.StartTime
+		}
+		if e := flowEnd(f); e.After(end) {
+			end = e
+		}
+	}
+	return start, end
+}
+
+// 仅含会话的事件证据
+func flowEvidence(ids []uint) []utils.EventEvidence {
+	evidence := make([]utils.EventEvidence, 0, len(ids))
+	for _, id := range ids {
+		evidence = append(evidence, utils.EventEvidence{LogType: utils.EvidenceTcp, LogID: id})
+	}
+	return evidence
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"awesomeProject1/backend/utils"
+	"time"
+)
+
+// 分析时间窗口（闭区间），按攻击发生时间而非入库时间划定
+type TimeWindow struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// 起止时间均未指定
+func (w TimeWindow) IsZero() bool {
+	return w.Start.IsZero() && w.End.IsZero()
+}
+
+// 两个窗口的交集，无交集时ok为false
+func (w TimeWindow) Intersect(other TimeWindow) (result TimeWindow, ok bool) {
+	result = w
+	if other.Start.After(result.Start) {
+		result.Start = other.Start
+	}
+	if other.End.Before(result.End) {
+		result.End = other.End
+	}
+	return result, !result.End.Before(result.Start)
+}
+
+func (w TimeWindow) String() string {
+	return w.Start.Format("2006-01-02 15:04:05") + " ~ " + w.End.Format("2006-01-02 15:04:05")
+}
+
+// 日志存储：攻击日志与TCP会话日志（按案件隔离）
+type FlowStore interface {
+	// 案件内在[start, end]内发生的攻击日志
+	AttackLogs(caseID uint, start, end time.Time) ([]utils.AttackLog, error)
+	// 指定客户端在[start, end]内开始的会话
+	Flows(caseID uint, clientIP string, start, end time.Time) ([]utils.TcpLog, error)
+	// 指定客户端在before之前开始的会话（用于建立历史画像）
+	FlowsBefore(caseID uint, clientIP string, before time.Time) ([]utils.TcpLog, error)
+	// 案件内攻击日志与会话日志覆盖的时间范围，无日志时返回零值
+	DataRange(caseID uint) (TimeWindow, error)
+	// 案件内已入库日志的最大ID
+	IngestWatermark(caseID uint) (utils.AnalysisWatermark, error)
+	// 案件内ID大于after的日志覆盖的时间范围，无新日志时返回零值
+	IngestedRange(caseID uint, after utils.AnalysisWatermark) (TimeWindow, error)
+}
+
+// 事件存储
+type EventStore interface {
+	// 保存一组事件，案件内指纹已存在的事件更新而非新增；全部成功或全部失败
+	SaveEvents(events []utils.APTEvent) error
+	// 案件内活动时间（StartTime~EndTime）与[start, end]重叠的事件，按开始时间升序
+	ListEvents(caseID uint, start, end time.Time) ([]*utils.APTEvent, error)
+	// 案件上次成功分析时的水位，从未分析时返回零值
+	Watermark(caseID uint) (utils.AnalysisWatermark, error)
+	// 记录案件成功分析时的水位
+	SaveWatermark(w utils.AnalysisWatermark) error
+}
+
+// 攻击图存储
+type GraphStore interface {
+	// 替换案件的攻击图，其他案件的图不受影响
+	ReplaceGraph(caseID uint, nodes []AttackNode, edges []AttackEdge) error
+	// 读取案件的攻击图
+	LoadGraph(caseID uint) ([]AttackNode, []AttackEdge, error)
+}
@@ -3,10 +3,10 @@ package model
 import (
 	"awesomeProject1/backend/utils"
 	"fmt"
-	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
-	"gorm.io/gorm"
 	"log"
 	"math"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -18,20 +18,26 @@ const (
 	PhaseDataExfiltration = "DataExfiltration"
 	PhaseDefenseEvasion   = "DefenseEvasion"   // 防御阶段
 	PhaseCredentialAccess = "CredentialAccess" // 访问凭证
+	PhasePersistence      = "Persistence"      // 执行与持久化（木马植入）
 )
 
 var eventTypeMapping = map[string]string{
 	// 攻击者检测事件
 	EventBruteForce:    PhaseInitialAccess,   // 高频暴力破解
 	EventNewConnection: PhaseInitialAccess,   // 新IP连接
-	EventPortScan:      PhaseLateralMovement, // 端口扫描
+	EventPortScan:      PhaseInitialAccess,   // 端口扫描（侦察）
 	EventProtoAnomaly:  PhaseLateralMovement, // 协议异常
+	EventHiddenAttack:  PhaseInitialAccess,   // 攻击日志未记录的攻击会话
 
 	// 受害者检测事件
 	EventReverseConnection:   PhaseC2,               // 反向连接
 	EventDataTransfer:        PhaseDataExfiltration, // 数据渗出
 	EventMaliciousConnection: PhaseInitialAccess,    // 恶意IP连接
 	EventLongConnection:      PhaseC2,               // 长连接
+	EventC2Communication:     PhaseC2,               // 周期性信标通信
+	EventLateralMovement:     PhaseLateralMovement,  // 内网管理服务横向移动
+	EventPivotChain:          PhaseLateralMovement,  // 跳板链
+	EventTrojanImplant:       PhasePersistence,      // 木马植入
 
 	// 肉鸡检测事件
 	EventZombieActivity:        PhaseC2, // 肉鸡新连接
@@ -40,19 +46,39 @@ var eventTypeMapping = map[string]string{
 	"ZOMBIE_MALICIOUS_CONN":    PhaseC2, // 肉鸡恶意连接
 }
 
+// 攻击图节点与边的类别
+const (
+	NodePhase = "phase" // 攻击阶段节点
+	NodeHost  = "host"  // 主机节点（跳板链）
+	EdgePivot = "pivot" // 主机间的跳板关系，阶段转移边的类别为空
+)
+
+// 攻击图节点，Phase为节点标识：阶段节点为阶段名，主机节点为HostNodeKey(IP)
 type AttackNode struct {
-	Phase       string    `neo4j:"phase"`
-	Timestamp   time.Time `neo4j:"timestamp"`
-	SourceIP    string    `neo4j:"sourceIP"`
-	DestIP      string    `neo4j:"destIP"`
-	RelatedLogs []uint
+	Phase       string    `neo4j:"phase" json:"phase"`
+	Kind        string    `neo4j:"kind" json:"kind"`
+	Timestamp   time.Time `neo4j:"timestamp" json:"timestamp"`
+	SourceIP    string    `neo4j:"sourceIP" json:"sourceIP"`
+	DestIP      string    `neo4j:"destIP" json:"destIP"`
+	RelatedLogs []uint    `json:"relatedLogs,omitempty"`
 }
 
 type AttackEdge struct {
-	From       string
-	To         string
-	Confidence float64
-	Count      int
+	From       string  `json:"from"`
+	To         string  `json:"to"`
+	Kind       string  `json:"kind,omitempty"`
+	Confidence float64 `json:"confidence"`
+	Count      int     `json:"count"`
+}
+
+// 主机节点标识
+func HostNodeKey(ip string) string {
+	return NodeHost + ":" + ip
+}
+
+// 主机节点标识对应的IP
+func HostNodeIP(key string) string {
+	return strings.TrimPrefix(key, NodeHost+":")
 }
 
 type AttackPath struct {
@@ -61,17 +87,20 @@ type AttackPath struct {
 }
 
 type TemporalCorrelator struct {
-	db            *gorm.DB
+	events        EventStore
+	caseID        uint
 	timeWindow    time.Duration
 	phaseSequence map[string][]string
 }
 
-func NewTemporalCorrelator(db *gorm.DB) *TemporalCorrelator {
+func NewTemporalCorrelator(events EventStore, caseID uint) *TemporalCorrelator {
 	return &TemporalCorrelator{
-		db:         db,
+		events:     events,
+		caseID:     caseID,
 		timeWindow: 30 * time.Minute,
 		phaseSequence: map[string][]string{
-			PhaseInitialAccess:   {PhaseLateralMovement, PhaseC2},
+			PhaseInitialAccess:   {PhaseLateralMovement, PhaseC2, PhasePersistence},
+			PhasePersistence:     {PhaseC2, PhaseLateralMovement},
 			PhaseLateralMovement: {PhaseC2, PhaseDataExfiltration},
 			PhaseC2:              {PhaseDataExfiltration},
 		},
@@ -103,12 +132,9 @@ func (tc *TemporalCorrelator) filterValidSequence(phases []AttackNode) []AttackN
 func (tc *TemporalCorrelator) DetectPhaseTransitions(start, end time.Time) ([]AttackNode, error) {
 	log.Printf("[阶段检测] 时间范围: %s ~ %s", start.Format("2006-01-02 15:04"), end.Format("2006-01-02 15:04"))
 
-	var events []*utils.APTEvent
-	if err := tc.db.Unscoped().
-		Where("created_at BETWEEN ? AND ?", start.Format("2006-01-02 15:04"), end.Format("2006-01-02 15:04")).
-		Order("created_at ASC").
-		Find(&events).Error; err != nil {
-		log.Printf("[错误] 数据库查询失败: %v", err)
+	events, err := tc.events.ListEvents(tc.caseID, start, end)
+	if err != nil {
+		log.Printf("[错误] 事件查询失败: %v", err)
 		return nil, err
 	}
 
@@ -160,6 +186,9 @@ func (tc *TemporalCorrelator) detectSinglePhase(events []*utils.APTEvent) *Attac
 		// 修改为使用统一的事件类型映射
 		if phase, exists := eventTypeMapping[event.EventName]; exists {
 			matchedPhase = phase
+		} else if knownPhases[event.EventType] {
+			// 自定义规则事件直接使用其声明的攻击阶段
+			matchedPhase = event.EventType
 		}
 
 		if matchedPhase == "" {
@@ -208,17 +237,19 @@ func (tc *TemporalCorrelator) detectSinglePhase(events []*utils.APTEvent) *Attac
 }
 
 type AttackGraphBuilder struct {
+	CaseID       uint // 图节点按案件隔离
 	Nodes        map[string]AttackNode
 	Edges        map[string]*AttackEdge
 	transitionMu sync.RWMutex
-	neo4jDriver  neo4j.Driver
+	graph        GraphStore
 }
 
-func NewAttackGraphBuilder() *AttackGraphBuilder {
+func NewAttackGraphBuilder(graph GraphStore, caseID uint) *AttackGraphBuilder {
 	return &AttackGraphBuilder{
-		Nodes:       make(map[string]AttackNode),
-		Edges:       make(map[string]*AttackEdge),
-		neo4jDriver: utils.Neo4jDriver,
+		CaseID: caseID,
+		Nodes:  make(map[string]AttackNode),
+		Edges:  make(map[string]*AttackEdge),
+		graph:  graph,
 	}
 }
 
@@ -230,6 +261,7 @@ func (bg *AttackGraphBuilder) AddPhaseTransition(from, to AttackNode) {
 	bg.transitionMu.Lock()
 	defer bg.transitionMu.Unlock()
 
+	from.Kind, to.Kind = NodePhase, NodePhase
 	bg.Nodes[from.Phase] = from
 	bg.Nodes[to.Phase] = to
 
@@ -250,68 +282,64 @@ func (bg *AttackGraphBuilder) AddPhaseTransition(from, to AttackNode) {
 		from.Phase, to.Phase, len(bg.Nodes), len(bg.Edges))
 }
 
-func (bg *AttackGraphBuilder) SaveToNeo4j() error {
-	if bg.neo4jDriver == nil {
-		return fmt.Errorf("Neo4j驱动未初始化")
+// 将跳板链写入攻击图：链上每台主机一个节点，相邻主机间为跳板边
+func (bg *AttackGraphBuilder) AddPivotChain(chain PivotChain) {
+	if len(chain.Hops) == 0 {
+		return
 	}
 
-	session := bg.neo4jDriver.NewSession(neo4j.SessionConfig{})
-	defer session.Close()
-
-	if _, err := session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
-		_, err := tx.Run("MATCH (n:AttackPhase) DETACH DELETE n", nil)
-		return nil, err
-	}); err != nil {
-		return fmt.Errorf("清空数据失败: %v", err)
-	}
+	bg.transitionMu.Lock()
+	defer bg.transitionMu.Unlock()
 
-	nodeIDs := make(map[string]int64)
-	for phase, node := range bg.Nodes {
-		result, err := session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
-			return tx.Run(
-				`MERGE (n:AttackPhase {phase: $phase}) 
-				ON CREATE SET n.timestamp = $timestamp,
-					n.sourceIP = $sourceIP,
-					n.destIP = $destIP
-				RETURN id(n)`,
-				map[string]interface{}{
-					"phase":     phase,
-					"timestamp": node.Timestamp.Unix(),
-					"sourceIP":  node.SourceIP,
-					"destIP":    node.DestIP,
-				})
-		})
-		if err != nil {
-			return fmt.Errorf("创建节点失败: %v", err)
+	for _, hop := range chain.Hops {
+		for _, ip := range []string{hop.From, hop.To} {
+			key := HostNodeKey(ip)
+			if node, exists := bg.Nodes[key]; exists && !hop.Time.Before(node.Timestamp) {
+				continue
+			}
+			bg.Nodes[key] = AttackNode{Phase: key, Kind: NodeHost, Timestamp: hop.Time, SourceIP: ip}
 		}
 
-		if records, ok := result.(neo4j.Result); ok && records.Next() {
-			nodeIDs[phase] = records.Record().Values[0].(int64)
+		key := HostNodeKey(hop.From) + "->" + HostNodeKey(hop.To)
+		if edge, exists := bg.Edges[key]; exists {
+			edge.Count++
+			edge.Confidence = math.Min(edge.Confidence+0.1, 1.0)
+		} else {
+			bg.Edges[key] = &AttackEdge{
+				From:       HostNodeKey(hop.From),
+				To:         HostNodeKey(hop.To),
+				Kind:       EdgePivot,
+				Confidence: 0.3,
+				Count:      1,
+			}
 		}
 	}
 
+	log.Printf("[攻击图] 添加跳板链 %s (节点:%d 边:%d)", chain, len(bg.Nodes), len(bg.Edges))
+}
+
+// 保存攻击图，替换案件原有的图
+func (bg *AttackGraphBuilder) Save() error {
+	if bg.graph == nil {
+		return fmt.Errorf("图存储未初始化")
+	}
+
+	bg.transitionMu.RLock()
+	nodes := make([]AttackNode, 0, len(bg.Nodes))
+	for _, node := range bg.Nodes {
+		nodes = append(nodes, node)
+	}
+	edges := make([]AttackEdge, 0, len(bg.Edges))
 	for _, edge := range bg.Edges {
-		_, err := session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
-			return tx.Run(
-				`MATCH (a:AttackPhase {phase: $from}), (b:AttackPhase {phase: $to})
-				MERGE (a)-[r:TRANSITION_TO]->(b)
-				SET r.confidence = $conf,
-					r.count = $count,
-					r.lastUpdated = timestamp()`,
-				map[string]interface{}{
-					"from":  edge.From,
-					"to":    edge.To,
-					"conf":  edge.Confidence,
-					"count": edge.Count,
-				})
-		})
-		if err != nil {
-			return fmt.Errorf("创建关系失败: %v", err)
-		}
+		edges = append(edges, *edge)
 	}
+	bg.transitionMu.RUnlock()
 
-	log.Printf("[Neo4j] 存储完成 (节点:%d 边:%d)", len(bg.Nodes), len(bg.Edges))
-	return nil
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Phase < nodes[j].Phase })
+	sort.Slice(edges, func(i, j int) bool {
+		return edges[i].From+"->"+edges[i].To < edges[j].From+"->"+edges[j].To
+	})
+	return bg.graph.ReplaceGraph(bg.CaseID, nodes, edges)
 }
 
 type BayesianInferer struct {
@@ -323,14 +351,20 @@ func NewBayesianInferer() *BayesianInferer {
 	return &BayesianInferer{
 		priorProb: map[string]float64{
 			PhaseInitialAccess:    0.2,
-			PhaseLateralMovement:  0.3,
-			PhaseC2:               0.4,
+			PhasePersistence:      0.1,
+			PhaseLateralMovement:  0.25,
+			PhaseC2:               0.35,
 			PhaseDataExfiltration: 0.1,
 		},
 		transitionProb: map[string]map[string]float64{
 			PhaseInitialAccess: {
-				PhaseLateralMovement: 0.6,
-				PhaseC2:              0.4,
+				PhaseLateralMovement: 0.5,
+				PhaseC2:              0.3,
+				PhasePersistence:     0.2,
+			},
+			PhasePersistence: {
+				PhaseC2:              0.7,
+				PhaseLateralMovement: 0.3,
 			},
 			PhaseLateralMovement: {
 				PhaseC2:               0.5,
@@ -365,11 +399,11 @@ func (bi *BayesianInferer) GeneratePaths(phases []AttackNode) []AttackPath {
 }
 
 func isInitialAccess(event *utils.APTEvent) bool {
-	return event != nil && (event.EventName == EventBruteForce || event.EventName == EventNewConnection)
+	return event != nil && (event.EventName == EventBruteForce || event.EventName == EventNewConnection || event.EventName == EventPortScan)
 }
 
 func isLateralMovement(event *utils.APTEvent) bool {
-	return event != nil && (event.EventName == EventPortScan || event.EventName == EventProtoAnomaly)
+	return event != nil && event.EventName == EventProtoAnomaly
 }
 
 func isC2(event *utils.APTEvent) bool {
@@ -377,5 +411,5 @@ func isC2(event *utils.APTEvent) bool {
 }
 
 func isDataExfiltration(event *utils.APTEvent) bool {
-	return event != nil && (event.EventName == EventDataTransfer || event.BytesSent > 100*1024*1024)
+	return event != nil && (event.EventName == EventDataTransfer || event.BytesSent > CurrentThresholds().For(event.SourceIP).ExfilBytes)
 }
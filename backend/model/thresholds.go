@@ -0,0 +1,292 @@
+package model
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 检测阈值，字段缺省时使用DefaultThresholds中的值
+type Thresholds struct {
+	PreAttackWindow      time.Duration `yaml:"preAttackWindow" json:"preAttackWindow"`           // 攻击前分析时间窗口
+	PostAttackWindow     time.Duration `yaml:"postAttackWindow" json:"postAttackWindow"`         // 攻击后分析时间窗口
+	FreqThreshold        int           `yaml:"freqThreshold" json:"freqThreshold"`               // 连接频率阈值（次/小时）
+	NewIPThreshold       int           `yaml:"newIPThreshold" json:"newIPThreshold"`             // 新IP数量阈值
+	MaliciousIPCheck     bool          `yaml:"maliciousIPCheck" json:"maliciousIPCheck"`         // 是否启用恶意IP检查
+	MaliciousIPs         []string      `yaml:"maliciousIPs" json:"maliciousIPs"`                 // 恶意服务器ip
+	ZombieWindow         time.Duration `yaml:"zombieWindow" json:"zombieWindow"`                 // 肉鸡检测时间窗口
+	ZombieNewIPThreshold int           `yaml:"zombieNewIPThreshold" json:"zombieNewIPThreshold"` // 肉鸡新IP连接阈值
+	ZombieConnThreshold  int           `yaml:"zombieConnThreshold" json:"zombieConnThreshold"`   // 肉鸡活动激增：窗口内最少连接数
+	ZombieReverseConns   int           `yaml:"zombieReverseConns" json:"zombieReverseConns"`     // 肉鸡反连攻击者次数阈值
+	ZombieSpikeFactor    int           `yaml:"zombieSpikeFactor" json:"zombieSpikeFactor"`       // 肉鸡活动激增倍数
+	OutboundSpikeFactor  int           `yaml:"outboundSpikeFactor" json:"outboundSpikeFactor"`   // 受害者外联激增倍数
+	ExfilBytes           int64         `yaml:"exfilBytes" json:"exfilBytes"`                     // 数据渗出字节阈值
+	C2Duration           time.Duration `yaml:"c2Duration" json:"c2Duration"`                     // C2长连接持续时间
+	ScanPortHits         int           `yaml:"scanPortHits" json:"scanPortHits"`                 // 端口扫描：单端口连接次数
+	ScanPortCount        int           `yaml:"scanPortCount" json:"scanPortCount"`               // 端口扫描：可疑端口数量
+	BeaconMinConns       int           `yaml:"beaconMinConns" json:"beaconMinConns"`             // 信标：同一服务端的最少连接次数
+	BeaconMinPeriod      time.Duration `yaml:"beaconMinPeriod" json:"beaconMinPeriod"`           // 信标：最短周期，更短的视为突发连接
+	BeaconMaxJitter      float64       `yaml:"beaconMaxJitter" json:"beaconMaxJitter"`           // 信标：间隔最大抖动（相对周期）
+	BeaconMinRegularity  float64       `yaml:"beaconMinRegularity" json:"beaconMinRegularity"`   // 信标：落在主周期附近的间隔占比下限
+	BeaconMaxByteJitter  float64       `yaml:"beaconMaxByteJitter" json:"beaconMaxByteJitter"`   // 信标：会话字节数变异系数上限
+	InternalNetworks     []string      `yaml:"internalNetworks" json:"internalNetworks"`         // 内网网段
+	LateralPorts         []int         `yaml:"lateralPorts" json:"lateralPorts"`                 // 横向移动：管理服务端口
+	LateralWindow        time.Duration `yaml:"lateralWindow" json:"lateralWindow"`               // 横向移动：受攻击后的观察窗口
+	LateralNewPeers      int           `yaml:"lateralNewPeers" json:"lateralNewPeers"`           // 横向移动：新内网主机数量阈值
+	PivotWindow          time.Duration `yaml:"pivotWindow" json:"pivotWindow"`                   // 跳板链：进入主机后向下一跳发起连接的时间窗口
+	PivotMaxHops         int           `yaml:"pivotMaxHops" json:"pivotMaxHops"`                 // 跳板链：最大跳数
+	ImplantPayloadBytes  int64         `yaml:"implantPayloadBytes" json:"implantPayloadBytes"`   // 木马植入：载荷最小字节数
+	ImplantSpikeFactor   int           `yaml:"implantSpikeFactor" json:"implantSpikeFactor"`     // 木马植入：载荷相对下载量中位数的倍数
+	ImplantCallback      time.Duration `yaml:"implantCallback" json:"implantCallback"`           // 木马植入：载荷送达后出现回连的时间窗口
+	ImplantPersist       time.Duration `yaml:"implantPersist" json:"implantPersist"`             // 木马植入：视为持久连接的最短时长
+	HiddenMatchWindow    time.Duration `yaml:"hiddenMatchWindow" json:"hiddenMatchWindow"`       // 隐藏攻击：会话与攻击日志匹配的时间容差
+}
+
+func DefaultThresholds() Thresholds {
+	return Thresholds{
+		PreAttackWindow:      preAttackWindow,
+		PostAttackWindow:     postAttackWindow,
+		FreqThreshold:        freqThreshold,
+		NewIPThreshold:       newIPThreshold,
+		MaliciousIPCheck:     maliciousIPCheck,
+		MaliciousIPs:         append([]string(nil), maliciousIPs...),
+		ZombieWindow:         zombieWindow,
+		ZombieNewIPThreshold: zombieNewIPThreshold,
+		ZombieConnThreshold:  zombieConnThreshold,
+		ZombieReverseConns:   3,
+		ZombieSpikeFactor:    5,
+		OutboundSpikeFactor:  3,
+		ExfilBytes:           100 * 1024 * 1024,
+		C2Duration:           time.Hour,
+		ScanPortHits:         3,
+		ScanPortCount:        5,
+		BeaconMinConns:       6,
+		BeaconMinPeriod:      10 * time.Second,
+		BeaconMaxJitter:      0.2,
+		BeaconMinRegularity:  0.7,
+		BeaconMaxByteJitter:  0.3,
+		InternalNetworks:     append([]string(nil), internalNetworks...),
+		LateralPorts:         append([]int(nil), lateralPorts...),
+		LateralWindow:        2 * time.Hour,
+		LateralNewPeers:      1,
+		PivotWindow:          time.Hour,
+		PivotMaxHops:         4,
+		ImplantPayloadBytes:  1024 * 1024,
+		ImplantSpikeFactor:   10,
+		ImplantCallback:      10 * time.Minute,
+		ImplantPersist:       10 * time.Minute,
+		HiddenMatchWindow:    10 * time.Minute,
+	}
+}
+
+func (t Thresholds) validate() error {
+	if t.PreAttackWindow <= 0 || t.PostAttackWindow <= 0 || t.ZombieWindow <= 0 {
+		return fmt.Errorf("时间窗口必须为正数")
+	}
+	if t.PostAttackWindow < time.Hour || t.ZombieWindow < time.Hour {
+		return fmt.Errorf("受害者与肉鸡时间窗口不能小于1小时")
+	}
+	if t.FreqThreshold < 1 || t.NewIPThreshold < 1 || t.ScanPortHits < 1 || t.ScanPortCount < 1 {
+		return fmt.Errorf("连接频率、新IP与端口扫描阈值不能小于1")
+	}
+	if t.ZombieNewIPThreshold < 1 || t.ZombieConnThreshold < 1 || t.ZombieReverseConns < 1 ||
+		t.ZombieSpikeFactor < 1 || t.OutboundSpikeFactor < 1 {
+		return fmt.Errorf("肉鸡与外联激增阈值不能小于1")
+	}
+	if t.ExfilBytes <= 0 || t.C2Duration <= 0 {
+		return fmt.Errorf("数据渗出与C2阈值必须为正数")
+	}
+	if t.BeaconMinConns < 3 || t.BeaconMinPeriod <= 0 {
+		return fmt.Errorf("信标检测至少需要3次连接且最短周期为正数")
+	}
+	if t.BeaconMaxJitter <= 0 || t.BeaconMaxByteJitter <= 0 || t.BeaconMinRegularity <= 0 || t.BeaconMinRegularity > 1 {
+		return fmt.Errorf("信标抖动阈值必须为正数，周期占比需在0-1之间")
+	}
+	for _, cidr := range t.InternalNetworks {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("内网网段格式错误: %s", cidr)
+		}
+	}
+	for _, port := range t.LateralPorts {
+		if port <= 0 || port > 65535 {
+			return fmt.Errorf("管理服务端口无效: %d", port)
+		}
+	}
+	if t.LateralWindow <= 0 || t.LateralNewPeers < 1 {
+		return fmt.Errorf("横向移动观察窗口必须为正数且新主机阈值不小于1")
+	}
+	// 横向移动只能观察到攻击后窗口内加载的会话
+	if t.LateralWindow > t.PostAttackWindow {
+		return fmt.Errorf("横向移动观察窗口不能大于攻击后分析时间窗口")
+	}
+	if t.PivotWindow <= 0 || t.PivotMaxHops < 2 {
+		return fmt.Errorf("跳板链时间窗口必须为正数且最大跳数不小于2")
+	}
+	if t.ImplantPayloadBytes <= 0 || t.ImplantSpikeFactor < 1 || t.ImplantCallback <= 0 || t.ImplantPersist <= 0 {
+		return fmt.Errorf("木马植入阈值必须为正数")
+	}
+	if t.HiddenMatchWindow < 0 {
+		return fmt.Errorf("隐藏攻击匹配容差不能为负数")
+	}
+	return nil
+}
+
+// 网段阈值覆盖
+type SubnetThresholds struct {
+	CIDR       string     `json:"cidr"`
+	Thresholds Thresholds `json:"thresholds"`
+	network    *net.IPNet
+}
+
+// 阈值配置：默认值加按网段的覆盖
+type ThresholdConfig struct {
+	Path     string             `json:"path"`
+	Default  Thresholds         `json:"default"`
+	Subnets  []SubnetThresholds `json:"subnets"`
+	LoadedAt time.Time          `json:"loadedAt"`
+}
+
+// 获取指定IP适用的阈值，多个网段匹配时掩码最长者优先
+func (cfg *ThresholdConfig) For(ip string) Thresholds {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return cfg.Default
+	}
+	for _, s := range cfg.Subnets {
+		if s.network.Contains(parsed) {
+			return s.Thresholds
+		}
+	}
+	return cfg.Default
+}
+
+// 新入库日志覆盖changed时需要重新分析的攻击日志时间范围：
+// 攻击前后的分析窗口内出现新会话，或攻击本身为新日志
+func (cfg *ThresholdConfig) AffectedWindow(changed TimeWindow) TimeWindow {
+	var before, after time.Duration
+	for _, t := range append([]Thresholds{cfg.Default}, cfg.subnetThresholds()...) {
+		before = max(before, t.PreAttackWindow)
+		after = max(after, t.PostAttackWindow, t.ZombieWindow)
+	}
+	return TimeWindow{Start: changed.Start.Add(-after), End: changed.End.Add(before)}
+}
+
+func (cfg *ThresholdConfig) subnetThresholds() []Thresholds {
+	result := make([]Thresholds, 0, len(cfg.Subnets))
+	for _, s := range cfg.Subnets {
+		result = append(result, s.Thresholds)
+	}
+	return result
+}
+
+type thresholdFile struct {
+	Defaults yaml.Node `yaml:"defaults"`
+	Subnets  []struct {
+		CIDR       string    `yaml:"cidr"`
+		Thresholds yaml.Node `yaml:"thresholds"`
+	} `yaml:"subnets"`
+}
+
+// 解析阈值配置文件（YAML，兼容JSON）
+func ParseThresholdConfig(data []byte) (*ThresholdConfig, error) {
+	var raw thresholdFile
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("阈值配置解析失败: %v", err)
+	}
+
+	cfg := &ThresholdConfig{Default: DefaultThresholds(), LoadedAt: time.Now()}
+	if raw.Defaults.Kind != 0 {
+		if err := raw.Defaults.Decode(&cfg.Default); err != nil {
+			return nil, fmt.Errorf("默认阈值解析失败: %v", err)
+		}
+	}
+	if err := cfg.Default.validate(); err != nil {
+		return nil, fmt.Errorf("默认阈值无效: %v", err)
+	}
+
+	for _, s := range raw.Subnets {
+		_, network, err := net.ParseCIDR(s.CIDR)
+		if err != nil {
+			return nil, fmt.Errorf("网段格式错误: %s", s.CIDR)
+		}
+		override := cfg.Default
+		override.MaliciousIPs = append([]string(nil), cfg.Default.MaliciousIPs...)
+		override.InternalNetworks = append([]string(nil), cfg.Default.InternalNetworks...)
+		override.LateralPorts = append([]int(nil), cfg.Default.LateralPorts...)
+		if s.Thresholds.Kind != 0 {
+			if err := s.Thresholds.Decode(&override); err != nil {
+				return nil, fmt.Errorf("网段%s阈值解析失败: %v", s.CIDR, err)
+			}
+		}
+		if err := override.validate(); err != nil {
+			return nil, fmt.Errorf("网段%s阈值无效: %v", s.CIDR, err)
+		}
+		cfg.Subnets = append(cfg.Subnets, SubnetThresholds{
+			CIDR:       network.String(),
+			Thresholds: override,
+			network:    network,
+		})
+	}
+
+	sort.SliceStable(cfg.Subnets, func(i, j int) bool {
+		oi, _ := cfg.Subnets[i].network.Mask.Size()
+		oj, _ := cfg.Subnets[j].network.Mask.Size()
+		return oi > oj
+	})
+	return cfg, nil
+}
+
+var (
+	thresholdMu      sync.Mutex
+	activeThresholds atomic.Pointer[ThresholdConfig]
+)
+
+func init() {
+	activeThresholds.Store(&ThresholdConfig{Default: DefaultThresholds()})
+}
+
+// 当前生效的阈值配置
+func CurrentThresholds() *ThresholdConfig {
+	return activeThresholds.Load()
+}
+
+// 加载阈值配置文件，文件不存在时保留内置默认值
+func LoadThresholds(path string) error {
+	thresholdMu.Lock()
+	defer thresholdMu.Unlock()
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		log.Printf("阈值配置文件不存在，使用内置默认值: %s", path)
+		activeThresholds.Store(&ThresholdConfig{Path: path, Default: DefaultThresholds(), LoadedAt: time.Now()})
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("阈值配置读取失败: %v", err)
+	}
+
+	cfg, err := ParseThresholdConfig(data)
+	if err != nil {
+		return err
+	}
+	cfg.Path = path
+	activeThresholds.Store(cfg)
+	log.Printf("阈值配置加载成功: %s (网段覆盖:%d)", path, len(cfg.Subnets))
+	return nil
+}
+
+// 重新加载当前配置文件，失败时保留原配置
+func ReloadThresholds() error {
+	path := CurrentThresholds().Path
+	if path == "" {
+		return fmt.Errorf("未指定阈值配置文件")
+	}
+	return LoadThresholds(path)
+}
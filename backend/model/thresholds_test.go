@@ -0,0 +1,93 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThresholdsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(t *Thresholds)
+		wantErr bool
+	}{
+		{"默认值", func(t *Thresholds) {}, false},
+		{"攻击前窗口为0", func(t *Thresholds) { t.PreAttackWindow = 0 }, true},
+		{"攻击后窗口小于1小时", func(t *Thresholds) { t.PostAttackWindow = 30 * time.Minute }, true},
+		{"连接频率为负", func(t *Thresholds) { t.FreqThreshold = -1 }, true},
+		{"新IP阈值为0", func(t *Thresholds) { t.NewIPThreshold = 0 }, true},
+		{"端口扫描次数为负", func(t *Thresholds) { t.ScanPortHits = -3 }, true},
+		{"端口扫描端口数为0", func(t *Thresholds) { t.ScanPortCount = 0 }, true},
+		{"肉鸡新IP阈值为负", func(t *Thresholds) { t.ZombieNewIPThreshold = -1 }, true},
+		{"外联激增倍数为0", func(t *Thresholds) { t.OutboundSpikeFactor = 0 }, true},
+		{"信标连接次数过少", func(t *Thresholds) { t.BeaconMinConns = 2 }, true},
+		{"信标周期占比大于1", func(t *Thresholds) { t.BeaconMinRegularity = 1.5 }, true},
+		{"内网网段格式错误", func(t *Thresholds) { t.InternalNetworks = []string{"10.0.0.0/33"} }, true},
+		{"管理端口越界", func(t *Thresholds) { t.LateralPorts = []int{70000} }, true},
+		{"横向移动窗口等于攻击后窗口", func(t *Thresholds) { t.LateralWindow = t.PostAttackWindow }, false},
+		{"横向移动窗口大于攻击后窗口", func(t *Thresholds) { t.LateralWindow = t.PostAttackWindow + time.Minute }, true},
+		{"跳板链跳数过少", func(t *Thresholds) { t.PivotMaxHops = 1 }, true},
+		{"隐藏攻击容差为0", func(t *Thresholds) { t.HiddenMatchWindow = 0 }, false},
+		{"隐藏攻击容差为负", func(t *Thresholds) { t.HiddenMatchWindow = -time.Minute }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := DefaultThresholds()
+			tt.modify(&th)
+			if err := th.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseThresholdConfig(t *testing.T) {
+	cfg, err := ParseThresholdConfig([]byte(`
+defaults:
+  freqThreshold: 200
+subnets:
+  - cidr: 10.0.0.0/8
+    thresholds:
+      freqThreshold: 50
+  - cidr: 10.1.0.0/16
+    thresholds:
+      freqThreshold: 20
+`))
+	if err != nil {
+		t.Fatalf("ParseThresholdConfig() error = %v", err)
+	}
+
+	tests := []struct {
+		ip   string
+		want int
+	}{
+		{"192.168.1.1", 200},
+		{"10.2.0.1", 50},
+		{"10.1.2.3", 20}, // 掩码最长者优先
+		{"not-an-ip", 200},
+	}
+	for _, tt := range tests {
+		if got := cfg.For(tt.ip).FreqThreshold; got != tt.want {
+			t.Errorf("For(%s).FreqThreshold = %d, want %d", tt.ip, got, tt.want)
+		}
+	}
+	// 网段覆盖未设置的项沿用默认值
+	if got := cfg.For("10.1.2.3").PostAttackWindow; got != cfg.Default.PostAttackWindow {
+		t.Errorf("网段阈值PostAttackWindow = %v, want %v", got, cfg.Default.PostAttackWindow)
+	}
+}
+
+func TestParseThresholdConfigErrors(t *testing.T) {
+	tests := map[string]string{
+		"默认阈值无效": "defaults:\n  newIPThreshold: -1\n",
+		"网段格式错误": "subnets:\n  - cidr: 10.0.0.0\n",
+		"网段阈值无效": "subnets:\n  - cidr: 10.0.0.0/8\n    thresholds:\n      newIPThreshold: 0\n",
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseThresholdConfig([]byte(data)); err == nil {
+				t.Errorf("ParseThresholdConfig() 未返回错误")
+			}
+		})
+	}
+}
@@ -12,9 +12,22 @@ func SetupRouter() *gin.Engine {
 	apiGroup := router.Group("/api/v1")
 	{
 		apiGroup.POST("/upload", handler.UploadHandler)
+		apiGroup.POST("/analyze", handler.AnalyzeHandler)
 		apiGroup.POST("/inquire", handler.InquireHandler)
 		apiGroup.GET("/refresh", handler.RefreshHandler)
 		apiGroup.POST("/quaryAPT", handler.QuaryAPTEvents)
+		apiGroup.GET("/events/:id/evidence", handler.EventEvidenceHandler)
+		apiGroup.GET("/detectors", handler.ListDetectorsHandler)
+		apiGroup.PUT("/detectors/:name", handler.UpdateDetectorHandler)
+		apiGroup.GET("/tcp-profiles", handler.ListTcpProfilesHandler)
+		apiGroup.GET("/quarantine", handler.QuarantineHandler)
+		apiGroup.GET("/files", handler.ListLogFilesHandler)
+		apiGroup.GET("/cases", handler.ListCasesHandler)
+		apiGroup.POST("/cases", handler.CreateCaseHandler)
+		apiGroup.GET("/graph", handler.GraphHandler)
+		apiGroup.GET("/jobs", handler.ListJobsHandler)
+		apiGroup.GET("/jobs/:id", handler.JobStatusHandler)
+		apiGroup.DELETE("/jobs/:id", handler.CancelJobHandler)
 	}
 
 	return router
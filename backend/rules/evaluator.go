@@ -0,0 +1,246 @@
+package rules
+
+import (
+	"awesomeProject1/backend/utils"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type fieldDef struct {
+	numeric bool
+	num     func(f *utils.TcpLog) float64
+	str     func(f *utils.TcpLog) string
+}
+
+func numField(get func(f *utils.TcpLog) float64) fieldDef {
+	return fieldDef{
+		numeric: true,
+		num:     get,
+		str:     func(f *utils.TcpLog) string { return strconv.FormatFloat(get(f), 'f', -1, 64) },
+	}
+}
+
+func strField(get func(f *utils.TcpLog) string) fieldDef {
+	return fieldDef{str: get}
+}
+
+// 规则可引用的TcpLog字段（与数据库列名一致）
+var fields = map[string]fieldDef{
+	"client_ip":       strField(func(f *utils.TcpLog) string { return f.ClientIP }),
+	"server_ip":       strField(func(f *utils.TcpLog) string { return f.ServerIP }),
+	"client_port":     numField(func(f *utils.TcpLog) float64 { return float64(f.ClientPort) }),
+	"server_port":     numField(func(f *utils.TcpLog) float64 { return float64(f.ServerPort) }),
+	"protocol":        numField(func(f *utils.TcpLog) float64 { return float64(f.Protocol) }),
+	"flow_status":     numField(func(f *utils.TcpLog) float64 { return float64(f.FlowStatus) }),
+	"duration":        numField(func(f *utils.TcpLog) float64 { return f.Duration }),
+	"ttl_server":      numField(func(f *utils.TcpLog) float64 { return float64(f.TTLServer) }),
+	"ttl_client":      numField(func(f *utils.TcpLog) float64 { return float64(f.TTLClient) }),
+	"client_plr":      numField(func(f *utils.TcpLog) float64 { return f.ClientPLR }),
+	"server_plr":      numField(func(f *utils.TcpLog) float64 { return f.ServerPLR }),
+	"down_bps":        numField(func(f *utils.TcpLog) float64 { return float64(f.DownBPS) }),
+	"up_bps":          numField(func(f *utils.TcpLog) float64 { return float64(f.UpBPS) }),
+	"down_bytes":      numField(func(f *utils.TcpLog) float64 { return float64(f.DownBytes) }),
+	"up_bytes":        numField(func(f *utils.TcpLog) float64 { return float64(f.UpBytes) }),
+	"packets_sent":    numField(func(f *utils.TcpLog) float64 { return float64(f.PacketsSent) }),
+	"packets_receive": numField(func(f *utils.TcpLog) float64 { return float64(f.PacketReceive) }),
+}
+
+// 规则命中结果
+type Match struct {
+	Group string    // 分组键，如 client_ip=1.2.3.4
+	Value float64   // 聚合值
+	Start time.Time // 命中窗口开始
+	End   time.Time // 命中窗口结束
+	Flows int       // 窗口内会话数
+	IDs   []uint    // 窗口内会话ID
+}
+
+func compare(op string, a, b float64) bool {
+	switch op {
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case "=":
+		return a == b
+	case "!=":
+		return a != b
+	}
+	return false
+}
+
+func (c Condition) match(f *utils.TcpLog) bool {
+	def := fields[c.Field]
+	if c.IsStr {
+		eq := def.str(f) == c.Str
+		return eq == (c.Op == "=")
+	}
+	return compare(c.Op, def.num(f), c.Num)
+}
+
+func (r *Rule) filter(f *utils.TcpLog) bool {
+	for _, c := range r.Where {
+		if !c.match(f) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Rule) groupKey(f *utils.TcpLog) string {
+	parts := make([]string, len(r.GroupBy))
+	for i, g := range r.GroupBy {
+		parts[i] = g + "=" + fields[g].str(f)
+	}
+	return strings.Join(parts, ",")
+}
+
+// 滑动窗口聚合状态
+type window struct {
+	rule     *Rule
+	count    int
+	sum      float64
+	distinct map[string]int
+	flows    []*utils.TcpLog
+}
+
+func (w *window) add(f *utils.TcpLog) {
+	w.flows = append(w.flows, f)
+	w.count++
+	if w.rule.Field == "" {
+		return
+	}
+	def := fields[w.rule.Field]
+	if w.rule.Distinct {
+		w.distinct[def.str(f)]++
+	} else if def.numeric {
+		w.sum += def.num(f)
+	}
+}
+
+func (w *window) removeFirst() {
+	f := w.flows[0]
+	w.flows = w.flows[1:]
+	w.count--
+	if w.rule.Field == "" {
+		return
+	}
+	def := fields[w.rule.Field]
+	if w.rule.Distinct {
+		key := def.str(f)
+		if w.distinct[key]--; w.distinct[key] == 0 {
+			delete(w.distinct, key)
+		}
+	} else if def.numeric {
+		w.sum -= def.num(f)
+	}
+}
+
+func (w *window) value() float64 {
+	switch w.rule.Agg {
+	case "count":
+		if w.rule.Distinct {
+			return float64(len(w.distinct))
+		}
+		return float64(w.count)
+	case "sum":
+		return w.sum
+	case "avg":
+		if w.count == 0 {
+			return 0
+		}
+		return w.sum / float64(w.count)
+	case "max", "min":
+		def := fields[w.rule.Field]
+		v := math.Inf(1)
+		if w.rule.Agg == "max" {
+			v = math.Inf(-1)
+		}
+		for _, f := range w.flows {
+			if w.rule.Agg == "max" {
+				v = math.Max(v, def.num(f))
+			} else {
+				v = math.Min(v, def.num(f))
+			}
+		}
+		return v
+	}
+	return 0
+}
+
+// 在会话集合上评估规则，每个分组返回聚合值最大（比较方向为<时最小）的命中窗口
+func (r *Rule) Evaluate(flows []utils.TcpLog) []Match {
+	groups := make(map[string][]*utils.TcpLog)
+	var keys []string
+	for i := range flows {
+		f := &flows[i]
+		if !r.filter(f) {
+			continue
+		}
+		key := r.groupKey(f)
+		if _, exists := groups[key]; !exists {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], f)
+	}
+	sort.Strings(keys)
+
+	var matches []Match
+	for _, key := range keys {
+		members := groups[key]
+		sort.SliceStable(members, func(i, j int) bool {
+			return members[i].StartTime.Before(members[j].StartTime)
+		})
+
+		w := &window{rule: r, distinct: make(map[string]int)}
+		var best *Match
+		var bestFrom, bestTo int
+		for i, f := range members {
+			w.add(f)
+			for r.Within > 0 && f.StartTime.Sub(w.flows[0].StartTime) > r.Within {
+				w.removeFirst()
+			}
+			v := w.value()
+			if !compare(r.Op, v, r.Threshold) {
+				continue
+			}
+			better := best == nil ||
+				(strings.HasPrefix(r.Op, "<") && v < best.Value) ||
+				(!strings.HasPrefix(r.Op, "<") && v > best.Value)
+			if better {
+				best = &Match{
+					Group: key,
+					Value: v,
+					Start: w.flows[0].StartTime,
+					End:   f.StartTime,
+					Flows: len(w.flows),
+				}
+				bestFrom, bestTo = i-len(w.flows)+1, i+1
+			}
+		}
+		if best != nil {
+			for _, f := range members[bestFrom:bestTo] {
+				best.IDs = append(best.IDs, f.ID)
+			}
+			matches = append(matches, *best)
+		}
+	}
+	return matches
+}
+
+func (m Match) String() string {
+	group := m.Group
+	if group == "" {
+		group = "全部"
+	}
+	return fmt.Sprintf("[%s] 聚合值%.2f 会话%d个 (%s ~ %s)", group, m.Value, m.Flows,
+		m.Start.Format("2006-01-02 15:04:05"), m.End.Format("2006-01-02 15:04:05"))
+}
@@ -0,0 +1,78 @@
+package rules
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"awesomeProject1/backend/utils"
+)
+
+var base = time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+
+func flow(id uint, offset time.Duration, client, server string, port int, up int64) utils.TcpLog {
+	return utils.TcpLog{ID: id, StartTime: base.Add(offset), ClientIP: client, ServerIP: server, ServerPort: port, UpBytes: up}
+}
+
+func TestEvaluate(t *testing.T) {
+	flows := []utils.TcpLog{
+		flow(1, 0, "10.0.0.1", "10.0.0.9", 21, 10),
+		flow(2, time.Minute, "10.0.0.1", "10.0.0.9", 22, 10),
+		flow(3, 2*time.Minute, "10.0.0.1", "10.0.0.9", 23, 10),
+		flow(4, 20*time.Minute, "10.0.0.1", "10.0.0.9", 24, 10),
+		flow(5, 0, "10.0.0.2", "10.0.0.9", 80, 500),
+		flow(6, time.Minute, "10.0.0.2", "10.0.0.9", 443, 500),
+		flow(7, 2*time.Minute, "10.0.0.2", "10.0.0.8", 8443, 700),
+	}
+
+	tests := []struct {
+		expr string
+		want []Match
+	}{
+		{
+			// 第4个会话超出5分钟窗口，窗口内仅3个不同端口
+			expr: "count(distinct server_port) >= 3 within 5m where server_ip = 10.0.0.9 group by client_ip",
+			want: []Match{{Group: "client_ip=10.0.0.1", Value: 3, Start: base, End: base.Add(2 * time.Minute), Flows: 3, IDs: []uint{1, 2, 3}}},
+		},
+		{
+			expr: "count(distinct server_port) > 3 within 5m group by client_ip",
+			want: nil,
+		},
+		{
+			expr: "sum(up_bytes) > 600 where server_port != 80 and server_port != 443 group by client_ip",
+			want: []Match{{Group: "client_ip=10.0.0.2", Value: 700, Start: base.Add(2 * time.Minute), End: base.Add(2 * time.Minute), Flows: 1, IDs: []uint{7}}},
+		},
+		{
+			expr: "count(*) >= 2 where server_ip = 10.0.0.9 group by client_ip, server_ip",
+			want: []Match{
+				{Group: "client_ip=10.0.0.1,server_ip=10.0.0.9", Value: 4, Start: base, End: base.Add(20 * time.Minute), Flows: 4, IDs: []uint{1, 2, 3, 4}},
+				{Group: "client_ip=10.0.0.2,server_ip=10.0.0.9", Value: 2, Start: base, End: base.Add(time.Minute), Flows: 2, IDs: []uint{5, 6}},
+			},
+		},
+		{
+			// 比较方向为<时取聚合值最小的窗口
+			expr: "avg(up_bytes) < 600 group by client_ip",
+			want: []Match{
+				{Group: "client_ip=10.0.0.1", Value: 10, Start: base, End: base, Flows: 1, IDs: []uint{1}},
+				{Group: "client_ip=10.0.0.2", Value: 500, Start: base, End: base, Flows: 1, IDs: []uint{5}},
+			},
+		},
+		{
+			// 聚合值首次达到最大时的窗口，之后的会话不再提高聚合值
+			expr: "max(up_bytes) >= 700",
+			want: []Match{{Group: "", Value: 700, Start: base, End: base.Add(2 * time.Minute), Flows: 6, IDs: []uint{1, 5, 2, 6, 3, 7}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			rule, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			got := rule.Evaluate(flows)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Evaluate() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
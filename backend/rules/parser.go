@@ -0,0 +1,296 @@
+package rules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// 规则表达式示例：
+//
+//	count(distinct server_port) > 20 within 5m group by client_ip
+//	sum(up_bytes) >= 104857600 where server_port != 80 and server_port != 443 within 1h group by client_ip, server_ip
+//
+// 语法：
+//
+//	rule   := agg op number { clause }
+//	agg    := count(*) | count(field) | count(distinct field) | sum(field) | avg(field) | max(field) | min(field)
+//	clause := where cond { and cond } | within duration | group by field { , field }
+//	cond   := field op (number | string)
+type Rule struct {
+	Source    string
+	Agg       string // count/sum/avg/max/min
+	Field     string // 聚合字段，count(*)时为空
+	Distinct  bool
+	Op        string
+	Threshold float64
+	Where     []Condition
+	Within    time.Duration // 滑动窗口长度，0表示整个输入
+	GroupBy   []string
+}
+
+type Condition struct {
+	Field string
+	Op    string
+	Num   float64
+	Str   string
+	IsStr bool
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func lex(src string) ([]token, error) {
+	var tokens []token
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')' || r == ',' || r == '*':
+			tokens = append(tokens, token{tokPunct, string(r), i})
+			i++
+		case r == '>' || r == '<' || r == '=' || r == '!':
+			start := i
+			i++
+			if i < len(runes) && runes[i] == '=' {
+				i++
+			}
+			op := string(runes[start:i])
+			if op == "!" {
+				return nil, fmt.Errorf("位置%d: 无效运算符 !", start)
+			}
+			if op == "==" {
+				op = "="
+			}
+			tokens = append(tokens, token{tokOp, op, start})
+		case r == '\'' || r == '"':
+			start := i
+			i++
+			for i < len(runes) && runes[i] != r {
+				i++
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("位置%d: 字符串未闭合", start)
+			}
+			tokens = append(tokens, token{tokString, string(runes[start+1 : i]), start})
+			i++
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			// 数字（可带负号）或时长（5m、1h30m）或IP地址
+			start := i
+			i++
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{tokNumber, string(runes[start:i]), start})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, token{tokIdent, strings.ToLower(string(runes[start:i])), start})
+		default:
+			return nil, fmt.Errorf("位置%d: 无法识别的字符 %q", i, r)
+		}
+	}
+	return append(tokens, token{tokEOF, "", len(runes)}), nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(kind tokenKind, text string) error {
+	t := p.next()
+	if t.kind != kind || (text != "" && t.text != text) {
+		return fmt.Errorf("位置%d: 期望%q，实际为%q", t.pos, text, t.text)
+	}
+	return nil
+}
+
+// 下一个词法单元是否为指定的关键字或标点，引号字符串不视为关键字
+func (p *parser) peekIs(kind tokenKind, text string) bool {
+	t := p.peek()
+	return t.kind == kind && t.text == text
+}
+
+func (p *parser) field() (string, error) {
+	t := p.next()
+	if t.kind != tokIdent {
+		return "", fmt.Errorf("位置%d: 期望字段名，实际为%q", t.pos, t.text)
+	}
+	if _, ok := fields[t.text]; !ok {
+		return "", fmt.Errorf("位置%d: 未知字段 %s", t.pos, t.text)
+	}
+	return t.text, nil
+}
+
+func (p *parser) op() (string, error) {
+	t := p.next()
+	if t.kind != tokOp {
+		return "", fmt.Errorf("位置%d: 期望比较运算符，实际为%q", t.pos, t.text)
+	}
+	return t.text, nil
+}
+
+// 解析规则表达式
+func Parse(src string) (*Rule, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	rule := &Rule{Source: src}
+
+	if err := p.parseAggregate(rule); err != nil {
+		return nil, err
+	}
+	if rule.Op, err = p.op(); err != nil {
+		return nil, err
+	}
+	t := p.next()
+	if t.kind != tokNumber {
+		return nil, fmt.Errorf("位置%d: 期望阈值数字，实际为%q", t.pos, t.text)
+	}
+	if rule.Threshold, err = strconv.ParseFloat(t.text, 64); err != nil {
+		return nil, fmt.Errorf("位置%d: 阈值格式错误 %s", t.pos, t.text)
+	}
+
+	for p.peek().kind != tokEOF {
+		t := p.next()
+		if t.kind != tokIdent {
+			return nil, fmt.Errorf("位置%d: 无法识别的子句 %q", t.pos, t.text)
+		}
+		switch t.text {
+		case "where":
+			if err := p.parseWhere(rule); err != nil {
+				return nil, err
+			}
+		case "within":
+			d := p.next()
+			if d.kind != tokNumber {
+				return nil, fmt.Errorf("位置%d: 期望时间窗口，实际为%q", d.pos, d.text)
+			}
+			if rule.Within, err = time.ParseDuration(d.text); err != nil || rule.Within <= 0 {
+				return nil, fmt.Errorf("位置%d: 时间窗口格式错误 %s", d.pos, d.text)
+			}
+		case "group":
+			if err := p.expect(tokIdent, "by"); err != nil {
+				return nil, err
+			}
+			for {
+				f, err := p.field()
+				if err != nil {
+					return nil, err
+				}
+				rule.GroupBy = append(rule.GroupBy, f)
+				if !p.peekIs(tokPunct, ",") {
+					break
+				}
+				p.next()
+			}
+		default:
+			return nil, fmt.Errorf("位置%d: 无法识别的子句 %q", t.pos, t.text)
+		}
+	}
+	return rule, nil
+}
+
+func (p *parser) parseAggregate(rule *Rule) error {
+	t := p.next()
+	if t.kind != tokIdent {
+		return fmt.Errorf("位置%d: 未知聚合函数 %q", t.pos, t.text)
+	}
+	switch t.text {
+	case "count", "sum", "avg", "max", "min":
+		rule.Agg = t.text
+	default:
+		return fmt.Errorf("位置%d: 未知聚合函数 %q", t.pos, t.text)
+	}
+	if err := p.expect(tokPunct, "("); err != nil {
+		return err
+	}
+
+	if rule.Agg == "count" && p.peekIs(tokPunct, "*") {
+		p.next()
+	} else {
+		if rule.Agg == "count" && p.peekIs(tokIdent, "distinct") {
+			p.next()
+			rule.Distinct = true
+		}
+		f, err := p.field()
+		if err != nil {
+			return err
+		}
+		if rule.Agg != "count" && !fields[f].numeric {
+			return fmt.Errorf("%s不支持非数值字段 %s", rule.Agg, f)
+		}
+		rule.Field = f
+	}
+	return p.expect(tokPunct, ")")
+}
+
+func (p *parser) parseWhere(rule *Rule) error {
+	for {
+		f, err := p.field()
+		if err != nil {
+			return err
+		}
+		op, err := p.op()
+		if err != nil {
+			return err
+		}
+		cond := Condition{Field: f, Op: op}
+		v := p.next()
+		switch {
+		case v.kind == tokString:
+			cond.Str, cond.IsStr = v.text, true
+		case v.kind == tokNumber && fields[f].numeric:
+			if cond.Num, err = strconv.ParseFloat(v.text, 64); err != nil {
+				return fmt.Errorf("位置%d: 数字格式错误 %s", v.pos, v.text)
+			}
+		case v.kind == tokNumber:
+			// 非数值字段允许直接书写IP地址
+			cond.Str, cond.IsStr = v.text, true
+		default:
+			return fmt.Errorf("位置%d: 期望条件值，实际为%q", v.pos, v.text)
+		}
+		if cond.IsStr && cond.Op != "=" && cond.Op != "!=" {
+			return fmt.Errorf("字符串条件仅支持=和!=: %s", f)
+		}
+		rule.Where = append(rule.Where, cond)
+
+		if !p.peekIs(tokIdent, "and") {
+			return nil
+		}
+		p.next()
+	}
+}
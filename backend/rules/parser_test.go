@@ -0,0 +1,93 @@
+package rules
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		src  string
+		want Rule
+	}{
+		{
+			src:  "count(*) > 10",
+			want: Rule{Agg: "count", Op: ">", Threshold: 10},
+		},
+		{
+			src: "count(distinct server_port) > 20 within 5m group by client_ip",
+			want: Rule{Agg: "count", Field: "server_port", Distinct: true, Op: ">", Threshold: 20,
+				Within: 5 * time.Minute, GroupBy: []string{"client_ip"}},
+		},
+		{
+			src: "sum(up_bytes) >= 104857600 where server_port != 80 and server_port != 443 within 1h group by client_ip, server_ip",
+			want: Rule{Agg: "sum", Field: "up_bytes", Op: ">=", Threshold: 104857600,
+				Where: []Condition{
+					{Field: "server_port", Op: "!=", Num: 80},
+					{Field: "server_port", Op: "!=", Num: 443},
+				},
+				Within: time.Hour, GroupBy: []string{"client_ip", "server_ip"}},
+		},
+		{
+			src: "avg(duration) < 1.5 where server_ip = 10.0.0.1",
+			want: Rule{Agg: "avg", Field: "duration", Op: "<", Threshold: 1.5,
+				Where: []Condition{{Field: "server_ip", Op: "=", Str: "10.0.0.1", IsStr: true}}},
+		},
+		{
+			src: "min(duration) > -1 where duration >= -0.5",
+			want: Rule{Agg: "min", Field: "duration", Op: ">", Threshold: -1,
+				Where: []Condition{{Field: "duration", Op: ">=", Num: -0.5}}},
+		},
+		{
+			src: "max(down_bytes) != 0 where client_ip != 'a b'",
+			want: Rule{Agg: "max", Field: "down_bytes", Op: "!=", Threshold: 0,
+				Where: []Condition{{Field: "client_ip", Op: "!=", Str: "a b", IsStr: true}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			got, err := Parse(tt.src)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			tt.want.Source = tt.src
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"median(duration) > 1",
+		"count(*)",
+		"count(*) > many",
+		"count(nope) > 1",
+		"sum(client_ip) > 1",
+		"count(*) > 1 within 5x",
+		"count(*) > 1 within 0s",
+		"count(*) > 1 group client_ip",
+		"count(*) > 1 where client_ip > '10.0.0.1'",
+		"count(*) > 1 where server_port >",
+		"count(*) > 1 limit 5",
+		"count(* > 1",
+		"'count'(*) > 1",
+		"count('*') > 1",
+		"count('distinct' server_port) > 1",
+		"count(*) > 1 'where' server_port = 1",
+		"count(*) > 1 where server_port = 1 'and' server_port = 2",
+		"count(*) > 1 group by client_ip ',' server_ip",
+		"count(*) > 1 within -5m",
+		"count(*) > - 1",
+	}
+	for _, src := range tests {
+		t.Run(src, func(t *testing.T) {
+			if _, err := Parse(src); err == nil {
+				t.Errorf("Parse(%q) 未返回错误", src)
+			}
+		})
+	}
+}
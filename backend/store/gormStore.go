@@ -0,0 +1,307 @@
+package store
+
+import (
+	"awesomeProject1/backend/model"
+	"awesomeProject1/backend/utils"
+	"encoding/json"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// 证据批量写入条数
+	evidenceBatchSize = 500
+	// 事件保存冲突（死锁、锁等待超时、唯一键冲突）时的最大尝试次数
+	saveEventsAttempts = 3
+)
+
+// 基于gorm的日志与事件存储
+type GormStore struct {
+	db *gorm.DB
+	// 串行化事件保存，避免同一指纹的合并相互覆盖
+	saveMu sync.Mutex
+}
+
+func NewGormStore(db *gorm.DB) *GormStore {
+	return &GormStore{db: db}
+}
+
+func (s *GormStore) AttackLogs(caseID uint, start, end time.Time) ([]utils.AttackLog, error) {
+	var attacks []utils.AttackLog
+	if err := s.db.Where("case_id = ? AND log_time BETWEEN ? AND ?", caseID, start, end).
+		Order("log_time ASC").
+		Find(&attacks).Error; err != nil {
+		return nil, fmt.Errorf("攻击日志查询失败: %v", err)
+	}
+	return attacks, nil
+}
+
+func (s *GormStore) Flows(caseID uint, clientIP string, start, end time.Time) ([]utils.TcpLog, error) {
+	var flows []utils.TcpLog
+	if err := s.db.Where("case_id = ? AND client_ip = ? AND start_time BETWEEN ? AND ?",
+		caseID,
+		clientIP,
+		start,
+		end,
+	).Find(&flows).Error; err != nil {
+		return nil, fmt.Errorf("会话日志查询失败: %v", err)
+	}
+	return flows, nil
+}
+
+func (s *GormStore) FlowsBefore(caseID uint, clientIP string, before time.Time) ([]utils.TcpLog, error) {
+	var flows []utils.TcpLog
+	if err := s.db.Where("case_id = ? AND client_ip = ? AND start_time < ?",
+		caseID,
+		clientIP,
+		before,
+	).Find(&flows).Error; err != nil {
+		return nil, fmt.Errorf("会话日志查询失败: %v", err)
+	}
+	return flows, nil
+}
+
+// 取表中指定列的最早或最晚时间，无记录时返回零值
+func (s *GormStore) boundary(query *gorm.DB, column string, latest bool) (time.Time, error) {
+	order := column + " ASC"
+	if latest {
+		order = column + " DESC"
+	}
+	var values []time.Time
+	if err := query.Order(order).Limit(1).Pluck(column, &values).Error; err != nil {
+		return time.Time{}, fmt.Errorf("时间范围查询失败: %v", err)
+	}
+	if len(values) == 0 {
+		return time.Time{}, nil
+	}
+	return values[0], nil
+}
+
+// 攻击日志与会话日志的时间范围，afterAttack/afterTcp之前的日志不计入
+func (s *GormStore) logRange(caseID, afterAttack, afterTcp uint) (model.TimeWindow, error) {
+	var window model.TimeWindow
+	attacks := func() *gorm.DB {
+		return s.db.Model(&utils.AttackLog{}).Where("case_id = ? AND id > ?", caseID, afterAttack)
+	}
+	flows := func() *gorm.DB {
+		return s.db.Model(&utils.TcpLog{}).Where("case_id = ? AND id > ?", caseID, afterTcp)
+	}
+	bounds := []struct {
+		query  *gorm.DB
+		column string
+		latest bool
+	}{
+		{attacks(), "log_time", false},
+		{attacks(), "log_time", true},
+		{flows(), "start_time", false},
+		{flows(), "start_time", true},
+		{flows(), "end_time", true},
+	}
+	for _, b := range bounds {
+		t, err := s.boundary(b.query, b.column, b.latest)
+		if err != nil {
+			return model.TimeWindow{}, err
+		}
+		window = extendWindow(window, t)
+	}
+	return window, nil
+}
+
+func (s *GormStore) DataRange(caseID uint) (model.TimeWindow, error) {
+	return s.logRange(caseID, 0, 0)
+}
+
+func (s *GormStore) IngestedRange(caseID uint, after utils.AnalysisWatermark) (model.TimeWindow, error) {
+	return s.logRange(caseID, after.AttackLogID, after.TcpLogID)
+}
+
+func (s *GormStore) IngestWatermark(caseID uint) (utils.AnalysisWatermark, error) {
+	w := utils.AnalysisWatermark{CaseID: caseID}
+	for _, m := range []struct {
+		model interface{}
+		id    *uint
+	}{
+		{&utils.AttackLog{}, &w.AttackLogID},
+		{&utils.TcpLog{}, &w.TcpLogID},
+	} {
+		var ids []uint
+		if err := s.db.Model(m.model).Where("case_id = ?", caseID).Order("id DESC").Limit(1).Pluck("id", &ids).Error; err != nil {
+			return w, fmt.Errorf("入库水位查询失败: %v", err)
+		}
+		if len(ids) > 0 {
+			*m.id = ids[0]
+		}
+	}
+	return w, nil
+}
+
+// 扩展时间范围使其包含t，零值时间忽略
+func extendWindow(w model.TimeWindow, t time.Time) model.TimeWindow {
+	if t.IsZero() {
+		return w
+	}
+	if w.Start.IsZero() || t.Before(w.Start) {
+		w.Start = t
+	}
+	if w.End.IsZero() || t.After(w.End) {
+		w.End = t
+	}
+	return w
+}
+
+func (s *GormStore) SaveEvents(events []utils.APTEvent) error {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	// 其他进程并发写入同一指纹时事务可能因冲突回滚，整批重试
+	var err error
+	for attempt := 1; attempt <= saveEventsAttempts; attempt++ {
+		if attempt > 1 {
+			log.Printf("事件保存冲突，第%d次重试: %v", attempt-1, err)
+			time.Sleep(time.Duration(attempt-1) * 100 * time.Millisecond)
+		}
+		err = s.db.Transaction(func(tx *gorm.DB) error {
+			for i := range events {
+				if err := saveEvent(tx, &events[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("事件保存失败: %v", err)
+}
+
+// 新增事件；案件内指纹已存在时合并到已有事件：扩展起止时间、取较高严重等级、
+// 合并结构化信息，并追加证据与重算触发次数
+func saveEvent(tx *gorm.DB, e *utils.APTEvent) error {
+	if e.Fingerprint == "" {
+		e.Fingerprint = e.ComputeFingerprint()
+	}
+	if e.Occurrences == 0 {
+		e.Occurrences = 1
+	}
+	e.ID = 0
+	e.UpdatedAt = time.Now()
+
+	// 冲突时未插入，ID保持为0
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(e).Error; err != nil {
+		return err
+	}
+	if e.ID == 0 {
+		var existing utils.APTEvent
+		// 加锁读取，合并期间其他事务不能修改该事件
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("case_id = ? AND fingerprint = ?", e.CaseID, e.Fingerprint).
+			First(&existing).Error; err != nil {
+			return err
+		}
+		mergeEvent(&existing, e)
+		if err := tx.Model(&existing).Updates(map[string]interface{}{
+			"start_time":     existing.StartTime,
+			"end_time":       existing.EndTime,
+			"severity_level": existing.SeverityLevel,
+			"description":    existing.Description,
+			"metadata":       existing.Metadata,
+		}).Error; err != nil {
+			return err
+		}
+		e.ID = existing.ID
+	}
+
+	if len(e.Evidence) == 0 {
+		return nil
+	}
+	for i := range e.Evidence {
+		e.Evidence[i].ID = 0
+		e.Evidence[i].EventID = e.ID
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&e.Evidence, evidenceBatchSize).Error; err != nil {
+		return err
+	}
+	var occurrences int64
+	if err := tx.Model(&utils.EventEvidence{}).
+		Where("event_id = ? AND log_type = ?", e.ID, utils.EvidenceAttack).
+		Count(&occurrences).Error; err != nil {
+		return err
+	}
+	if occurrences == 0 {
+		return nil
+	}
+	e.Occurrences = int(occurrences)
+	return tx.Model(&utils.APTEvent{}).Where("id = ?", e.ID).UpdateColumn("occurrences", occurrences).Error
+}
+
+// 将新检测到的事件合并到已有事件。描述随严重等级取较高的一次检测，
+// 结构化信息按键合并，新检测的同名键覆盖已有值，已有的其他键保留
+func mergeEvent(existing, incoming *utils.APTEvent) {
+	if incoming.StartTime.Before(existing.StartTime) {
+		existing.StartTime = incoming.StartTime
+	}
+	if incoming.EndTime.After(existing.EndTime) {
+		existing.EndTime = incoming.EndTime
+	}
+	if incoming.SeverityLevel > existing.SeverityLevel || existing.Description == "" {
+		existing.SeverityLevel = max(existing.SeverityLevel, incoming.SeverityLevel)
+		existing.Description = incoming.Description
+	}
+	existing.Metadata = mergeMetadata(existing.Metadata, incoming.Metadata)
+}
+
+// 合并两份JSON对象；任一方不是JSON对象时无法按键合并，以新检测的为准
+func mergeMetadata(existing, incoming json.RawMessage) json.RawMessage {
+	if len(incoming) == 0 {
+		return existing
+	}
+	if len(existing) == 0 {
+		return incoming
+	}
+	var base, update map[string]json.RawMessage
+	if json.Unmarshal(existing, &base) != nil || json.Unmarshal(incoming, &update) != nil || base == nil || update == nil {
+		return incoming
+	}
+	for k, v := range update {
+		base[k] = v
+	}
+	merged, err := json.Marshal(base)
+	if err != nil {
+		return incoming
+	}
+	return merged
+}
+
+func (s *GormStore) ListEvents(caseID uint, start, end time.Time) ([]*utils.APTEvent, error) {
+	var events []*utils.APTEvent
+	if err := s.db.Unscoped().
+		Where("case_id = ? AND start_time <= ? AND end_time >= ?", caseID, end, start).
+		Order("start_time ASC, id ASC").
+		Find(&events).Error; err != nil {
+		return nil, fmt.Errorf("事件查询失败: %v", err)
+	}
+	return events, nil
+}
+
+func (s *GormStore) Watermark(caseID uint) (utils.AnalysisWatermark, error) {
+	var marks []utils.AnalysisWatermark
+	if err := s.db.Where("case_id = ?", caseID).Limit(1).Find(&marks).Error; err != nil {
+		return utils.AnalysisWatermark{}, fmt.Errorf("分析水位查询失败: %v", err)
+	}
+	if len(marks) == 0 {
+		return utils.AnalysisWatermark{CaseID: caseID}, nil
+	}
+	return marks[0], nil
+}
+
+func (s *GormStore) SaveWatermark(w utils.AnalysisWatermark) error {
+	if err := s.db.Save(&w).Error; err != nil {
+		return fmt.Errorf("分析水位保存失败: %v", err)
+	}
+	return nil
+}
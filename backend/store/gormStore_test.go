@@ -0,0 +1,75 @@
+package store
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"awesomeProject1/backend/migrate"
+	"awesomeProject1/backend/utils"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_pragma=journal_mode(WAL)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrate.Up(db, 0); err != nil {
+		t.Fatalf("migrate.Up() error = %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func TestGormStoreConcurrentSaveEvents(t *testing.T) {
+	s := NewGormStore(openTestDB(t))
+	start := time.Date(2024, 3, 1, 8, 10, 0, 0, time.UTC)
+
+	// 多个检测协程同时保存同一指纹的事件，均应合并到同一事件
+	const workers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- s.SaveEvents([]utils.APTEvent{{
+				CaseID: 1, Detector: "port_scan", EventName: "PortScan", EventType: "InitialAccess",
+				SourceIP: "10.0.0.1", DestIP: "10.0.0.9", StartTime: start, EndTime: start.Add(time.Duration(i) * time.Minute),
+				SeverityLevel: 3, Metadata: []byte(fmt.Sprintf(`{"k%d":%d}`, i, i)),
+				Evidence: []utils.EventEvidence{{LogType: utils.EvidenceAttack, LogID: uint(i + 1)}},
+			}})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("SaveEvents() error = %v", err)
+		}
+	}
+
+	var events []utils.APTEvent
+	if err := s.db.Find(&events).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("事件数 = %d, want 1", len(events))
+	}
+	if e := events[0]; e.Occurrences != workers || !e.EndTime.Equal(start.Add((workers-1)*time.Minute)) {
+		t.Errorf("合并后事件 Occurrences = %d EndTime = %v, want %d %v",
+			e.Occurrences, e.EndTime, workers, start.Add((workers-1)*time.Minute))
+	}
+}
@@ -0,0 +1,43 @@
+package store
+
+import (
+	"awesomeProject1/backend/model"
+	"awesomeProject1/backend/utils"
+	"fmt"
+	"gorm.io/gorm"
+	"log"
+)
+
+// 攻击图存储类型
+const (
+	GraphAuto  = "auto"  // 优先Neo4j，不可用时使用SQL
+	GraphNeo4j = "neo4j" // 仅Neo4j，不可用时启动失败
+	GraphSQL   = "sql"   // 嵌入式SQL邻接表
+)
+
+// 攻击图存储配置
+type GraphConfig struct {
+	Kind     string `yaml:"store"`
+	URI      string `yaml:"uri"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// 按配置打开攻击图存储，SQL存储使用db所在的数据库
+func OpenGraphStore(cfg GraphConfig, db *gorm.DB) (model.GraphStore, error) {
+	switch cfg.Kind {
+	case GraphSQL:
+		return NewSQLGraphStore(db), nil
+	case GraphNeo4j, GraphAuto:
+		err := utils.InitNeo4j(cfg.URI, cfg.Username, cfg.Password)
+		if err == nil {
+			return NewNeo4jGraphStore(utils.Neo4jDriver), nil
+		}
+		if cfg.Kind == GraphNeo4j {
+			return nil, err
+		}
+		log.Printf("%v，改用嵌入式图存储", err)
+		return NewSQLGraphStore(db), nil
+	}
+	return nil, fmt.Errorf("不支持的图存储类型: %s", cfg.Kind)
+}
@@ -0,0 +1,267 @@
+package store
+
+import (
+	"awesomeProject1/backend/model"
+	"awesomeProject1/backend/utils"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 内存存储，实现全部存储接口，用于单元测试与离线演示
+type MemoryStore struct {
+	mu         sync.RWMutex
+	attacks    []utils.AttackLog
+	flows      []utils.TcpLog
+	events     []utils.APTEvent
+	evidence   []utils.EventEvidence
+	graphs     map[uint]memoryGraph
+	watermarks map[uint]utils.AnalysisWatermark
+	nextID     uint
+}
+
+type memoryGraph struct {
+	nodes []model.AttackNode
+	edges []model.AttackEdge
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{graphs: make(map[uint]memoryGraph), watermarks: make(map[uint]utils.AnalysisWatermark)}
+}
+
+// 写入攻击日志，未设置ID时按写入顺序分配
+func (s *MemoryStore) AddAttackLogs(logs ...utils.AttackLog) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, l := range logs {
+		if l.ID == 0 {
+			l.ID = uint(len(s.attacks) + 1)
+		}
+		s.attacks = append(s.attacks, l)
+	}
+}
+
+// 写入会话日志，未设置ID时按写入顺序分配
+func (s *MemoryStore) AddFlows(flows ...utils.TcpLog) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, f := range flows {
+		if f.ID == 0 {
+			f.ID = uint(len(s.flows) + 1)
+		}
+		s.flows = append(s.flows, f)
+	}
+}
+
+func (s *MemoryStore) AttackLogs(caseID uint, start, end time.Time) ([]utils.AttackLog, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var result []utils.AttackLog
+	for _, l := range s.attacks {
+		if l.CaseID == caseID && !l.LogTime.Before(start) && !l.LogTime.After(end) {
+			result = append(result, l)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].LogTime.Before(result[j].LogTime) })
+	return result, nil
+}
+
+func (s *MemoryStore) Flows(caseID uint, clientIP string, start, end time.Time) ([]utils.TcpLog, error) {
+	return s.filterFlows(func(f utils.TcpLog) bool {
+		return f.CaseID == caseID && f.ClientIP == clientIP &&
+			!f.StartTime.Before(start) && !f.StartTime.After(end)
+	}), nil
+}
+
+func (s *MemoryStore) FlowsBefore(caseID uint, clientIP string, before time.Time) ([]utils.TcpLog, error) {
+	return s.filterFlows(func(f utils.TcpLog) bool {
+		return f.CaseID == caseID && f.ClientIP == clientIP && f.StartTime.Before(before)
+	}), nil
+}
+
+func (s *MemoryStore) DataRange(caseID uint) (model.TimeWindow, error) {
+	return s.IngestedRange(caseID, utils.AnalysisWatermark{})
+}
+
+func (s *MemoryStore) IngestedRange(caseID uint, after utils.AnalysisWatermark) (model.TimeWindow, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var window model.TimeWindow
+	for _, l := range s.attacks {
+		if l.CaseID == caseID && l.ID > after.AttackLogID {
+			window = extendWindow(window, l.LogTime)
+		}
+	}
+	for _, f := range s.flows {
+		if f.CaseID == caseID && f.ID > after.TcpLogID {
+			window = extendWindow(extendWindow(window, f.StartTime), f.EndTime)
+		}
+	}
+	return window, nil
+}
+
+func (s *MemoryStore) IngestWatermark(caseID uint) (utils.AnalysisWatermark, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	w := utils.AnalysisWatermark{CaseID: caseID}
+	for _, l := range s.attacks {
+		if l.CaseID == caseID && l.ID > w.AttackLogID {
+			w.AttackLogID = l.ID
+		}
+	}
+	for _, f := range s.flows {
+		if f.CaseID == caseID && f.ID > w.TcpLogID {
+			w.TcpLogID = f.ID
+		}
+	}
+	return w, nil
+}
+
+func (s *MemoryStore) filterFlows(match func(utils.TcpLog) bool) []utils.TcpLog {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var result []utils.TcpLog
+	for _, f := range s.flows {
+		if match(f) {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+func (s *MemoryStore) SaveEvents(events []utils.APTEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for i := range events {
+		e := &events[i]
+		if e.Fingerprint == "" {
+			e.Fingerprint = e.ComputeFingerprint()
+		}
+		if e.Occurrences == 0 {
+			e.Occurrences = 1
+		}
+		e.UpdatedAt = now
+
+		stored := s.findEvent(e.CaseID, e.Fingerprint)
+		if stored != nil {
+			mergeEvent(stored, e)
+			stored.UpdatedAt = now
+		} else {
+			s.nextID++
+			e.ID = s.nextID
+			if e.CreatedAt.IsZero() {
+				e.CreatedAt = now
+			}
+			evidence := e.Evidence
+			e.Evidence = nil
+			s.events = append(s.events, *e)
+			e.Evidence = evidence
+			stored = &s.events[len(s.events)-1]
+		}
+		e.ID = stored.ID
+
+		for _, ev := range e.Evidence {
+			if !s.hasEvidence(stored.ID, ev.LogType, ev.LogID) {
+				ev.ID = uint(len(s.evidence) + 1)
+				ev.EventID = stored.ID
+				ev.CreatedAt = now
+				s.evidence = append(s.evidence, ev)
+			}
+		}
+		if n := s.countEvidence(stored.ID, utils.EvidenceAttack); n > 0 {
+			stored.Occurrences = n
+		}
+		e.Occurrences = stored.Occurrences
+	}
+	return nil
+}
+
+func (s *MemoryStore) hasEvidence(eventID uint, logType string, logID uint) bool {
+	for _, ev := range s.evidence {
+		if ev.EventID == eventID && ev.LogType == logType && ev.LogID == logID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *MemoryStore) countEvidence(eventID uint, logType string) int {
+	n := 0
+	for _, ev := range s.evidence {
+		if ev.EventID == eventID && ev.LogType == logType {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *MemoryStore) findEvent(caseID uint, fingerprint string) *utils.APTEvent {
+	for i := range s.events {
+		if s.events[i].CaseID == caseID && s.events[i].Fingerprint == fingerprint {
+			return &s.events[i]
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) Watermark(caseID uint) (utils.AnalysisWatermark, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if w, ok := s.watermarks[caseID]; ok {
+		return w, nil
+	}
+	return utils.AnalysisWatermark{CaseID: caseID}, nil
+}
+
+func (s *MemoryStore) SaveWatermark(w utils.AnalysisWatermark) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.UpdatedAt = time.Now()
+	s.watermarks[w.CaseID] = w
+	return nil
+}
+
+func (s *MemoryStore) ListEvents(caseID uint, start, end time.Time) ([]*utils.APTEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var result []*utils.APTEvent
+	for i := range s.events {
+		e := s.events[i]
+		if e.CaseID == caseID && !e.StartTime.After(end) && !e.EndTime.Before(start) {
+			result = append(result, &e)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].StartTime.Before(result[j].StartTime) })
+	return result, nil
+}
+
+// 案件内已保存的全部事件
+func (s *MemoryStore) Events(caseID uint) []utils.APTEvent {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var result []utils.APTEvent
+	for _, e := range s.events {
+		if e.CaseID == caseID {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+func (s *MemoryStore) ReplaceGraph(caseID uint, nodes []model.AttackNode, edges []model.AttackEdge) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.graphs[caseID] = memoryGraph{
+		nodes: append([]model.AttackNode(nil), nodes...),
+		edges: append([]model.AttackEdge(nil), edges...),
+	}
+	return nil
+}
+
+func (s *MemoryStore) LoadGraph(caseID uint) ([]model.AttackNode, []model.AttackEdge, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	g := s.graphs[caseID]
+	return g.nodes, g.edges, nil
+}
@@ -0,0 +1,113 @@
+package store
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"awesomeProject1/backend/utils"
+)
+
+func TestMergeMetadata(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string
+		incoming string
+		want     string
+	}{
+		{"新检测无信息", `{"a":1}`, ``, `{"a":1}`},
+		{"已有事件无信息", ``, `{"b":2}`, `{"b":2}`},
+		{"按键合并", `{"a":1,"b":2}`, `{"b":3,"c":4}`, `{"a":1,"b":3,"c":4}`},
+		{"嵌套对象整体覆盖", `{"a":{"x":1}}`, `{"a":{"y":2}}`, `{"a":{"y":2}}`},
+		{"已有信息不是对象", `[1,2]`, `{"a":1}`, `{"a":1}`},
+		{"新信息不是对象", `{"a":1}`, `"text"`, `"text"`},
+		{"null", `null`, `{"a":1}`, `{"a":1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeMetadata(json.RawMessage(tt.existing), json.RawMessage(tt.incoming))
+			if string(got) != tt.want {
+				t.Errorf("mergeMetadata() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeEvent(t *testing.T) {
+	start := time.Date(2024, 3, 1, 8, 10, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		incoming utils.APTEvent
+		want     utils.APTEvent
+	}{
+		{
+			name: "扩展时间并保留较高等级的描述",
+			incoming: utils.APTEvent{StartTime: start.Add(-5 * time.Minute), EndTime: start.Add(time.Hour),
+				SeverityLevel: 2, Description: "later", Metadata: json.RawMessage(`{"ports":[22]}`)},
+			want: utils.APTEvent{StartTime: start.Add(-5 * time.Minute), EndTime: start.Add(time.Hour),
+				SeverityLevel: 3, Description: "first", Metadata: json.RawMessage(`{"hosts":2,"ports":[22]}`)},
+		},
+		{
+			name: "更高等级的检测替换描述",
+			incoming: utils.APTEvent{StartTime: start, EndTime: start.Add(time.Minute),
+				SeverityLevel: 5, Description: "severe"},
+			want: utils.APTEvent{StartTime: start, EndTime: start.Add(30 * time.Minute),
+				SeverityLevel: 5, Description: "severe", Metadata: json.RawMessage(`{"hosts":2}`)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			existing := utils.APTEvent{StartTime: start, EndTime: start.Add(30 * time.Minute),
+				SeverityLevel: 3, Description: "first", Metadata: json.RawMessage(`{"hosts":2}`)}
+			mergeEvent(&existing, &tt.incoming)
+			if !reflect.DeepEqual(existing, tt.want) {
+				t.Errorf("mergeEvent() = %+v, want %+v", existing, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreSaveEvents(t *testing.T) {
+	s := NewMemoryStore()
+	start := time.Date(2024, 3, 1, 8, 10, 0, 0, time.UTC)
+	event := func(detector string, attackID uint, flowID uint, metadata string) utils.APTEvent {
+		return utils.APTEvent{
+			CaseID: 1, Detector: detector, EventName: "PortScan", EventType: "InitialAccess",
+			SourceIP: "10.0.0.1", DestIP: "10.0.0.9", StartTime: start, EndTime: start.Add(time.Minute),
+			SeverityLevel: 3, Metadata: json.RawMessage(metadata),
+			Evidence: []utils.EventEvidence{
+				{LogType: utils.EvidenceAttack, LogID: attackID},
+				{LogType: utils.EvidenceTcp, LogID: flowID},
+			},
+		}
+	}
+
+	if err := s.SaveEvents([]utils.APTEvent{
+		event("port_scan", 1, 10, `{"a":1}`),
+		event("port_scan", 2, 11, `{"b":2}`),
+		event("port_scan", 2, 11, `{"b":3}`), // 同一证据不重复计数
+		event("wide_port_sweep", 1, 10, `{}`),
+	}); err != nil {
+		t.Fatalf("SaveEvents() error = %v", err)
+	}
+
+	events := s.Events(1)
+	if len(events) != 2 {
+		t.Fatalf("事件数 = %d, want 2（不同规则的同名事件各自保存）", len(events))
+	}
+	byDetector := make(map[string]utils.APTEvent)
+	for _, e := range events {
+		byDetector[e.Detector] = e
+	}
+	scan := byDetector["port_scan"]
+	if scan.Occurrences != 2 {
+		t.Errorf("Occurrences = %d, want 2", scan.Occurrences)
+	}
+	if string(scan.Metadata) != `{"a":1,"b":3}` {
+		t.Errorf("Metadata = %s, want {\"a\":1,\"b\":3}", scan.Metadata)
+	}
+	if sweep := byDetector["wide_port_sweep"]; sweep.Occurrences != 1 || sweep.Fingerprint == scan.Fingerprint {
+		t.Errorf("wide_port_sweep事件 = %+v", sweep)
+	}
+}
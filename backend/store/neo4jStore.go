@@ -0,0 +1,196 @@
+package store
+
+import (
+	"awesomeProject1/backend/model"
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"log"
+	"time"
+)
+
+// 基于Neo4j的攻击图存储
+type Neo4jGraphStore struct {
+	driver neo4j.Driver
+}
+
+func NewNeo4jGraphStore(driver neo4j.Driver) *Neo4jGraphStore {
+	return &Neo4jGraphStore{driver: driver}
+}
+
+func (s *Neo4jGraphStore) ReplaceGraph(caseID uint, nodes []model.AttackNode, edges []model.AttackEdge) error {
+	if s.driver == nil {
+		return fmt.Errorf("Neo4j驱动未初始化")
+	}
+
+	session := s.driver.NewSession(neo4j.SessionConfig{})
+	defer session.Close()
+
+	// 清空与写入在同一事务中完成，任一步失败时保留原有攻击图
+	_, err := session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		if err := runCypher(tx, "MATCH (n {caseId: $caseId}) WHERE n:AttackPhase OR n:Host DETACH DELETE n",
+			map[string]interface{}{"caseId": int64(caseID)}); err != nil {
+			return nil, fmt.Errorf("清空数据失败: %v", err)
+		}
+
+		for _, node := range nodes {
+			label, prop := nodeLabel(node.Kind)
+			if err := runCypher(tx,
+				`MERGE (n:`+label+` {`+prop+`: $key, caseId: $caseId})
+				ON CREATE SET n.timestamp = $timestamp,
+					n.sourceIP = $sourceIP,
+					n.destIP = $destIP`,
+				map[string]interface{}{
+					"key":       neo4jKey(node.Kind, node.Phase),
+					"caseId":    int64(caseID),
+					"timestamp": node.Timestamp.Unix(),
+					"sourceIP":  node.SourceIP,
+					"destIP":    node.DestIP,
+				}); err != nil {
+				return nil, fmt.Errorf("创建节点失败: %v", err)
+			}
+		}
+
+		for _, edge := range edges {
+			kind := edgeNodeKind(edge.Kind)
+			label, prop := nodeLabel(kind)
+			if err := runCypher(tx,
+				`MATCH (a:`+label+` {`+prop+`: $from, caseId: $caseId}), (b:`+label+` {`+prop+`: $to, caseId: $caseId})
+				MERGE (a)-[r:`+relationType(edge.Kind)+`]->(b)
+				SET r.confidence = $conf,
+					r.count = $count,
+					r.lastUpdated = timestamp()`,
+				map[string]interface{}{
+					"from":   neo4jKey(kind, edge.From),
+					"to":     neo4jKey(kind, edge.To),
+					"caseId": int64(caseID),
+					"conf":   edge.Confidence,
+					"count":  edge.Count,
+				}); err != nil {
+				return nil, fmt.Errorf("创建关系失败: %v", err)
+			}
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[Neo4j] 存储完成 (案件:%d 节点:%d 边:%d)", caseID, len(nodes), len(edges))
+	return nil
+}
+
+func (s *Neo4jGraphStore) LoadGraph(caseID uint) ([]model.AttackNode, []model.AttackEdge, error) {
+	if s.driver == nil {
+		return nil, nil, fmt.Errorf("Neo4j驱动未初始化")
+	}
+
+	session := s.driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close()
+
+	params := map[string]interface{}{"caseId": int64(caseID)}
+	var nodes []model.AttackNode
+	var edges []model.AttackEdge
+	_, err := session.ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		for _, kind := range []string{model.NodePhase, model.NodeHost} {
+			label, prop := nodeLabel(kind)
+			result, err := tx.Run(
+				`MATCH (n:`+label+` {caseId: $caseId})
+				RETURN n.`+prop+`, n.timestamp, n.sourceIP, n.destIP
+				ORDER BY n.`+prop, params)
+			if err != nil {
+				return nil, err
+			}
+			for result.Next() {
+				values := result.Record().Values
+				ts, _ := values[1].(int64)
+				key, _ := values[0].(string)
+				node := model.AttackNode{Phase: graphKey(kind, key), Kind: kind, Timestamp: time.Unix(ts, 0)}
+				node.SourceIP, _ = values[2].(string)
+				node.DestIP, _ = values[3].(string)
+				nodes = append(nodes, node)
+			}
+			if err := result.Err(); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, edgeKind := range []string{"", model.EdgePivot} {
+			kind := edgeNodeKind(edgeKind)
+			label, prop := nodeLabel(kind)
+			result, err := tx.Run(
+				`MATCH (a:`+label+` {caseId: $caseId})-[r:`+relationType(edgeKind)+`]->(b:`+label+` {caseId: $caseId})
+				RETURN a.`+prop+`, b.`+prop+`, r.confidence, r.count
+				ORDER BY a.`+prop+`, b.`+prop, params)
+			if err != nil {
+				return nil, err
+			}
+			for result.Next() {
+				values := result.Record().Values
+				count, _ := values[3].(int64)
+				from, _ := values[0].(string)
+				to, _ := values[1].(string)
+				edge := model.AttackEdge{From: graphKey(kind, from), To: graphKey(kind, to), Kind: edgeKind, Count: int(count)}
+				edge.Confidence, _ = values[2].(float64)
+				edges = append(edges, edge)
+			}
+			if err := result.Err(); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("攻击图读取失败: %v", err)
+	}
+	return nodes, edges, nil
+}
+
+// 执行语句并等待结果，使语句错误在事务内返回
+func runCypher(tx neo4j.Transaction, query string, params map[string]interface{}) error {
+	result, err := tx.Run(query, params)
+	if err != nil {
+		return err
+	}
+	_, err = result.Consume()
+	return err
+}
+
+// 节点的标签与标识属性：阶段节点为AttackPhase(phase)，主机节点为Host(ip)，
+// 主机不写入AttackPhase标签，阶段查询不会混入主机
+func nodeLabel(kind string) (label, prop string) {
+	if kind == model.NodeHost {
+		return "Host", "ip"
+	}
+	return "AttackPhase", "phase"
+}
+
+// 边两端节点的类别
+func edgeNodeKind(edgeKind string) string {
+	if edgeKind == model.EdgePivot {
+		return model.NodeHost
+	}
+	return model.NodePhase
+}
+
+// 攻击图节点标识与Neo4j标识属性值的转换
+func neo4jKey(kind, key string) string {
+	if kind == model.NodeHost {
+		return model.HostNodeIP(key)
+	}
+	return key
+}
+
+func graphKey(kind, value string) string {
+	if kind == model.NodeHost {
+		return model.HostNodeKey(value)
+	}
+	return value
+}
+
+// 边类别对应的关系类型
+func relationType(kind string) string {
+	if kind == model.EdgePivot {
+		return "PIVOT_TO"
+	}
+	return "TRANSITION_TO"
+}
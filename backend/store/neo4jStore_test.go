@@ -0,0 +1,38 @@
+package store
+
+import (
+	"testing"
+
+	"awesomeProject1/backend/model"
+)
+
+func TestNeo4jNodeMapping(t *testing.T) {
+	tests := []struct {
+		kind      string
+		key       string
+		wantLabel string
+		wantProp  string
+		wantValue string
+	}{
+		{model.NodePhase, model.PhaseLateralMovement, "AttackPhase", "phase", model.PhaseLateralMovement},
+		{model.NodeHost, model.HostNodeKey("10.0.0.5"), "Host", "ip", "10.0.0.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			if label, prop := nodeLabel(tt.kind); label != tt.wantLabel || prop != tt.wantProp {
+				t.Errorf("nodeLabel() = %s, %s, want %s, %s", label, prop, tt.wantLabel, tt.wantProp)
+			}
+			value := neo4jKey(tt.kind, tt.key)
+			if value != tt.wantValue {
+				t.Errorf("neo4jKey() = %s, want %s", value, tt.wantValue)
+			}
+			if got := graphKey(tt.kind, value); got != tt.key {
+				t.Errorf("graphKey() = %s, want %s", got, tt.key)
+			}
+		})
+	}
+
+	if edgeNodeKind(model.EdgePivot) != model.NodeHost || edgeNodeKind("") != model.NodePhase {
+		t.Error("跳板边应连接主机节点，阶段转移边连接阶段节点")
+	}
+}
@@ -0,0 +1,128 @@
+package store
+
+import (
+	"awesomeProject1/backend/model"
+	"fmt"
+	"gorm.io/gorm"
+	"log"
+	"time"
+)
+
+// 攻击阶段节点（嵌入式图存储）
+type GraphNode struct {
+	ID        uint   `gorm:"primaryKey"`
+	CaseID    uint   `gorm:"uniqueIndex:idx_graph_nodes_case_phase,priority:1"`
+	Phase     string `gorm:"type:varchar(50);uniqueIndex:idx_graph_nodes_case_phase,priority:2"`
+	Kind      string `gorm:"type:varchar(16)"` // 节点类别：阶段或主机
+	Timestamp time.Time
+	SourceIP  string `gorm:"type:varchar(45)"`
+	DestIP    string `gorm:"type:varchar(45)"`
+}
+
+// 阶段转移边（嵌入式图存储）
+type GraphEdge struct {
+	ID          uint   `gorm:"primaryKey"`
+	CaseID      uint   `gorm:"uniqueIndex:idx_graph_edges_case_from_to,priority:1"`
+	FromPhase   string `gorm:"type:varchar(50);uniqueIndex:idx_graph_edges_case_from_to,priority:2"`
+	ToPhase     string `gorm:"type:varchar(50);uniqueIndex:idx_graph_edges_case_from_to,priority:3"`
+	Kind        string `gorm:"type:varchar(16)"` // 边类别：空为阶段转移，pivot为跳板关系
+	Confidence  float64
+	Count       int
+	LastUpdated time.Time
+}
+
+// 基于SQL邻接表的攻击图存储，无需Neo4j服务（数据表由migrate创建）
+type SQLGraphStore struct {
+	db *gorm.DB
+}
+
+func NewSQLGraphStore(db *gorm.DB) *SQLGraphStore {
+	return &SQLGraphStore{db: db}
+}
+
+func (s *SQLGraphStore) ReplaceGraph(caseID uint, nodes []model.AttackNode, edges []model.AttackEdge) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("case_id = ?", caseID).Delete(&GraphEdge{}).Error; err != nil {
+			return fmt.Errorf("清空数据失败: %v", err)
+		}
+		if err := tx.Where("case_id = ?", caseID).Delete(&GraphNode{}).Error; err != nil {
+			return fmt.Errorf("清空数据失败: %v", err)
+		}
+
+		for _, node := range nodes {
+			if err := tx.Create(&GraphNode{
+				CaseID:    caseID,
+				Phase:     node.Phase,
+				Kind:      node.Kind,
+				Timestamp: node.Timestamp,
+				SourceIP:  node.SourceIP,
+				DestIP:    node.DestIP,
+			}).Error; err != nil {
+				return fmt.Errorf("创建节点失败: %v", err)
+			}
+		}
+
+		now := time.Now()
+		for _, edge := range edges {
+			if err := tx.Create(&GraphEdge{
+				CaseID:      caseID,
+				FromPhase:   edge.From,
+				ToPhase:     edge.To,
+				Kind:        edge.Kind,
+				Confidence:  edge.Confidence,
+				Count:       edge.Count,
+				LastUpdated: now,
+			}).Error; err != nil {
+				return fmt.Errorf("创建关系失败: %v", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[图存储] 存储完成 (案件:%d 节点:%d 边:%d)", caseID, len(nodes), len(edges))
+	return nil
+}
+
+func (s *SQLGraphStore) LoadGraph(caseID uint) ([]model.AttackNode, []model.AttackEdge, error) {
+	var nodeRows []GraphNode
+	if err := s.db.Where("case_id = ?", caseID).Order("phase").Find(&nodeRows).Error; err != nil {
+		return nil, nil, fmt.Errorf("攻击图读取失败: %v", err)
+	}
+	var edgeRows []GraphEdge
+	if err := s.db.Where("case_id = ?", caseID).Order("from_phase, to_phase").Find(&edgeRows).Error; err != nil {
+		return nil, nil, fmt.Errorf("攻击图读取失败: %v", err)
+	}
+
+	nodes := make([]model.AttackNode, 0, len(nodeRows))
+	for _, n := range nodeRows {
+		nodes = append(nodes, model.AttackNode{
+			Phase:     n.Phase,
+			Kind:      nodeKind(n.Kind),
+			Timestamp: n.Timestamp,
+			SourceIP:  n.SourceIP,
+			DestIP:    n.DestIP,
+		})
+	}
+	edges := make([]model.AttackEdge, 0, len(edgeRows))
+	for _, e := range edgeRows {
+		edges = append(edges, model.AttackEdge{
+			From:       e.FromPhase,
+			To:         e.ToPhase,
+			Kind:       e.Kind,
+			Confidence: e.Confidence,
+			Count:      e.Count,
+		})
+	}
+	return nodes, edges, nil
+}
+
+// 升级前写入的节点没有类别，均为阶段节点
+func nodeKind(kind string) string {
+	if kind == "" {
+		return model.NodePhase
+	}
+	return kind
+}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+// 默认案件ID，启动时由EnsureDefaultCase设置
+var DefaultCaseID uint
+
+// 确保默认案件存在，并将未归属案件的历史数据归入默认案件（需在数据表迁移后调用）
+func EnsureDefaultCase(db *gorm.DB) error {
+	c := Case{Name: DefaultCaseName, Description: "未指定案件的数据"}
+	if err := db.Where("name = ?", DefaultCaseName).FirstOrCreate(&c).Error; err != nil {
+		return fmt.Errorf("默认案件创建失败: %v", err)
+	}
+	DefaultCaseID = c.ID
+
+	for _, model := range []interface{}{&AttackLog{}, &TcpLog{}, &APTEvent{}, &LogFile{}, &QuarantinedLine{}} {
+		if err := db.Model(model).Unscoped().Where("case_id = 0").Update("case_id", c.ID).Error; err != nil {
+			return fmt.Errorf("历史数据归档失败: %v", err)
+		}
+	}
+	return nil
+}
+
+// 解析请求中的案件ID，空值为默认案件
+func ResolveCase(db *gorm.DB, value string) (uint, error) {
+	if value == "" {
+		return DefaultCaseID, nil
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("无效案件ID: %s", value)
+	}
+	return LookupCase(db, uint(id))
+}
+
+// 校验案件存在，0为默认案件
+func LookupCase(db *gorm.DB, id uint) (uint, error) {
+	if id == 0 {
+		return DefaultCaseID, nil
+	}
+	var c Case
+	if err := db.First(&c, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, fmt.Errorf("案件不存在: %d", id)
+		}
+		return 0, fmt.Errorf("案件查询失败: %v", err)
+	}
+	return c.ID, nil
+}
@@ -3,8 +3,12 @@ package utils
 import (
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/glebarez/sqlite"
 	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -15,24 +19,84 @@ var (
 	Neo4jDriver neo4j.Driver
 )
 
-func InitDatabase() {
+// 数据库类型
+const (
+	DriverMySQL  = "mysql"
+	DriverSQLite = "sqlite" // 纯Go实现，无需CGO，适合离线单机使用
+)
+
+const (
+	DefaultSQLiteDSN = "data/aptrace.db"
+	sqlitePragmas    = "_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+)
+
+// 数据库连接配置
+type DBConfig struct {
+	Driver string `yaml:"driver"` // mysql或sqlite
+	DSN    string `yaml:"dsn"`    // sqlite为空时使用DefaultSQLiteDSN
+}
 
-	var err error
+func openDialector(cfg DBConfig) (gorm.Dialector, error) {
+	switch cfg.Driver {
+	case DriverMySQL:
+		if cfg.DSN == "" {
+			return nil, fmt.Errorf("未配置mysql连接串")
+		}
+		return mysql.New(mysql.Config{
+			DSN:                       cfg.DSN,
+			SkipInitializeWithVersion: true,
+		}), nil
 
-	LogDB, err = gorm.Open(mysql.New(mysql.Config{
-		DSN:                       "UserName:yourPassword@tcp(127.0.0.1:3306)/log_analysis?parseTime=true",
-		SkipInitializeWithVersion: true,
-	}), &gorm.Config{})
+	case DriverSQLite:
+		dsn := cfg.DSN
+		if dsn == "" {
+			dsn = DefaultSQLiteDSN
+		}
+		path := strings.TrimPrefix(strings.SplitN(dsn, "?", 2)[0], "file:")
+		if path != "" && path != ":memory:" {
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return nil, fmt.Errorf("数据目录创建失败: %v", err)
+			}
+		}
+		if !strings.Contains(dsn, "_pragma=") {
+			if strings.Contains(dsn, "?") {
+				dsn += "&" + sqlitePragmas
+			} else {
+				dsn += "?" + sqlitePragmas
+			}
+		}
+		return sqlite.Open(dsn), nil
+	}
+	return nil, fmt.Errorf("不支持的数据库类型: %s", cfg.Driver)
+}
+
+func InitDatabase(cfg DBConfig) error {
+	dialector, err := openDialector(cfg)
 	if err != nil {
-		log.Fatal("Failed to connect to the database:", err)
+		return err
+	}
+
+	LogDB, err = gorm.Open(dialector, &gorm.Config{})
+	if err != nil {
+		return fmt.Errorf("数据库连接失败: %v", err)
 	}
 	sqlDB, err := LogDB.DB()
+	if err != nil {
+		return fmt.Errorf("数据库连接失败: %v", err)
+	}
 	sqlDB.SetConnMaxIdleTime(time.Minute * 5)
 	sqlDB.SetConnMaxLifetime(time.Hour * 2)
 	sqlDB.SetMaxIdleConns(10)
 	sqlDB.SetMaxOpenConns(20)
 
+	if dialector.Name() == DriverSQLite {
+		// SQLite同一时间只允许一个写连接，串行化避免database is locked
+		sqlDB.SetMaxOpenConns(1)
+		log.Printf("sqlite初始化成功")
+		return nil
+	}
 	log.Printf("mysql初始化成功")
+	return nil
 }
 
 func InitNeo4j(uri, username, password string) error {
@@ -40,6 +104,10 @@ func InitNeo4j(uri, username, password string) error {
 	if err != nil {
 		return fmt.Errorf("Neo4j连接失败: %v", err)
 	}
+	if err := driver.VerifyConnectivity(); err != nil {
+		driver.Close()
+		return fmt.Errorf("Neo4j连接失败: %v", err)
+	}
 	Neo4jDriver = driver
 
 	log.Printf("neo4j初始化成功")
@@ -1,7 +1,12 @@
 package utils
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
 	"gorm.io/gorm"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -15,34 +20,37 @@ type AttackLog struct {
 	ID        uint      `gorm:"primaryKey"`
 	LogTime   time.Time `gorm:"column:log_time"`
 	EventType int       `gorm:"column:event_type"`
-	SourceIP  string    `gorm:"column:source_ip"`
+	SourceIP  string    `gorm:"column:source_ip;type:varchar(45)"`
 	Protocol  int       `gorm:"column:protocol"`
 	Action    string    `gorm:"column:action"`
-	DestIP    string    `gorm:"column:dest_ip"`
+	DestIP    string    `gorm:"column:dest_ip;type:varchar(45)"`
 	Severity  int       `gorm:"column:severity"`
+	CaseID    uint      `gorm:"column:case_id;index"`                     // 所属案件
+	FileID    uint      `gorm:"column:file_id;index"`                     // 来源文件
+	RowKey    string    `gorm:"column:row_key;type:char(40);uniqueIndex"` // 行自然键
 }
 
 type TcpLog struct {
 	ID              uint      `gorm:"primaryKey"`
-	LogTime         time.Time `gorm:"column:log_time"`         // SAVETIME
-	StartTime       time.Time `gorm:"column:start_time"`       // BEGINTIME
-	EndTime         time.Time `gorm:"column:end_time"`         // ENDTIME
-	EstablishedTime time.Time `gorm:"column:established_time"` // ESTABLISHTIME
-	FlowStatus      int       `gorm:"column:flow_status"`      // FLOWSTATUS
-	Duration        float64   `gorm:"column:duration"`         // SECONDS
-	ServerIP        string    `gorm:"column:server_ip"`        // SERVERIP
-	ServerPort      int       `gorm:"column:server_port"`      // SERVERPORT
-	ClientIP        string    `gorm:"column:client_ip"`        // CLIENTIP
-	ClientPort      int       `gorm:"column:client_port"`      // CLIENTPORT
-	TTLServer       int       `gorm:"column:ttl_server"`       // TTLSERVER
-	TTLClient       int       `gorm:"column:ttl_client"`       // TTLCLIENT
-	Protocol        int       `gorm:"column:protocol"`         // PROTOCOL
-	ClientPLR       float64   `gorm:"column:client_plr"`       // CLIENTPLR
-	ServerPLR       float64   `gorm:"column:server_plr"`       // SERVERPLR
-	DownBPS         int64     `gorm:"column:down_bps"`         // DOWNBPS
-	UpBPS           int64     `gorm:"column:up_bps"`           // UPBPS
-	DownBytes       int64     `gorm:"column:down_bytes"`       // DOWNBYTES
-	UpBytes         int64     `gorm:"column:up_bytes"`         // UPBYTES
+	LogTime         time.Time `gorm:"column:log_time"`                   // SAVETIME
+	StartTime       time.Time `gorm:"column:start_time"`                 // BEGINTIME
+	EndTime         time.Time `gorm:"column:end_time"`                   // ENDTIME
+	EstablishedTime time.Time `gorm:"column:established_time"`           // ESTABLISHTIME
+	FlowStatus      int       `gorm:"column:flow_status"`                // FLOWSTATUS
+	Duration        float64   `gorm:"column:duration"`                   // SECONDS
+	ServerIP        string    `gorm:"column:server_ip;type:varchar(45)"` // SERVERIP
+	ServerPort      int       `gorm:"column:server_port"`                // SERVERPORT
+	ClientIP        string    `gorm:"column:client_ip;type:varchar(45)"` // CLIENTIP
+	ClientPort      int       `gorm:"column:client_port"`                // CLIENTPORT
+	TTLServer       int       `gorm:"column:ttl_server"`                 // TTLSERVER
+	TTLClient       int       `gorm:"column:ttl_client"`                 // TTLCLIENT
+	Protocol        int       `gorm:"column:protocol"`                   // PROTOCOL
+	ClientPLR       float64   `gorm:"column:client_plr"`                 // CLIENTPLR
+	ServerPLR       float64   `gorm:"column:server_plr"`                 // SERVERPLR
+	DownBPS         int64     `gorm:"column:down_bps"`                   // DOWNBPS
+	UpBPS           int64     `gorm:"column:up_bps"`                     // UPBPS
+	DownBytes       int64     `gorm:"column:down_bytes"`                 // DOWNBYTES
+	UpBytes         int64     `gorm:"column:up_bytes"`                   // UPBYTES
 
 	// 保留字段（根据实际需要）
 	FragmentFlag  int `gorm:"column:fragment_flag"`
@@ -50,11 +58,85 @@ type TcpLog struct {
 	PacketsSent   int `gorm:"column:packets_sent"`
 	PacketReceive int `gorm:"column:packets_receive"`
 	CustomStatus  int `gorm:"column:custom_status"`
+
+	CaseID uint   `gorm:"column:case_id;index"`                     // 所属案件
+	FileID uint   `gorm:"column:file_id;index"`                     // 来源文件
+	RowKey string `gorm:"column:row_key;type:char(40);uniqueIndex"` // 行自然键
+}
+
+// 默认案件名称，未指定案件的上传与查询归入该案件
+const DefaultCaseName = "default"
+
+// 案件（调查），上传、事件、攻击图与查询均按案件隔离
+type Case struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"type:varchar(100);uniqueIndex" json:"name"`
+	Description string    `gorm:"type:text" json:"description"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// 上传的日志文件，按内容哈希识别同一案件内的重复上传
+type LogFile struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	CaseID     uint      `gorm:"uniqueIndex:idx_log_files_case_sha256,priority:1" json:"caseId"`
+	Name       string    `gorm:"type:varchar(255);index" json:"name"`
+	SHA256     string    `gorm:"column:sha256;type:char(64);uniqueIndex:idx_log_files_case_sha256,priority:2" json:"sha256"`
+	Size       int64     `json:"size"`
+	UploadID   string    `gorm:"type:varchar(32);index" json:"uploadId"`
+	Lines      int       `json:"lines"`
+	Accepted   int       `json:"accepted"`
+	Rejected   int       `json:"rejected"`
+	Duplicates int       `json:"duplicates"`
+	Status     string    `gorm:"type:varchar(16);default:'complete'" json:"status"` // 导入状态，仅complete视为已导入
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// 文件导入状态
+const (
+	LogFilePending  = "pending"  // 导入中，或导入被中断
+	LogFileComplete = "complete" // 导入成功
+	LogFileFailed   = "failed"   // 导入失败且残留数据未能清除
+)
+
+func rowKey(parts ...string) string {
+	sum := sha1.Sum([]byte(strings.Join(parts, "|")))
+	return hex.EncodeToString(sum[:])
+}
+
+// 攻击日志自然键：案件、时间、源/目标IP、协议、事件类型、行为、严重性
+func (l *AttackLog) NaturalKey() string {
+	return rowKey(
+		strconv.FormatUint(uint64(l.CaseID), 10),
+		l.LogTime.Format(time.RFC3339),
+		l.SourceIP, l.DestIP,
+		strconv.Itoa(l.Protocol),
+		strconv.Itoa(l.EventType),
+		l.Action,
+		strconv.Itoa(l.Severity),
+	)
+}
+
+// TCP会话自然键：案件、起止时间、五元组、上下行字节数
+func (l *TcpLog) NaturalKey() string {
+	return rowKey(
+		strconv.FormatUint(uint64(l.CaseID), 10),
+		l.StartTime.Format(time.RFC3339),
+		l.EndTime.Format(time.RFC3339),
+		l.ClientIP, strconv.Itoa(l.ClientPort),
+		l.ServerIP, strconv.Itoa(l.ServerPort),
+		strconv.Itoa(l.Protocol),
+		strconv.FormatInt(l.UpBytes, 10),
+		strconv.FormatInt(l.DownBytes, 10),
+	)
 }
 
 // APT事件主模型
 type APTEvent struct {
 	gorm.Model
+	CaseID        uint      `gorm:"index" json:"caseId"`                    // 所属案件
+	Fingerprint   string    `gorm:"type:char(40)" json:"fingerprint"`       // 事件指纹，与案件ID组成唯一索引
+	Occurrences   int       `gorm:"default:1" json:"occurrences"`           // 触发次数（不同攻击日志的数量）
+	Detector      string    `gorm:"type:varchar(100)" json:"detector"`      // 产生事件的检测规则
 	StartTime     time.Time `gorm:"index" json:"starttime"`                 // 事件开始时间
 	EndTime       time.Time `gorm:"index" json:"endtime"`                   // 事件结束时间
 	SourceIP      string    `gorm:"type:varchar(45);index" json:"sourceip"` // 源IP
@@ -71,6 +153,64 @@ type APTEvent struct {
 	StatusCode    int       `json:"status_code"`                            // 状态码
 	Retransmits   int       `json:"retransmits"`                            // 重传次数
 	Protocol      string    `json:"protocol"`                               // 协议类型
+
+	Metadata json.RawMessage `gorm:"type:text" json:"metadata,omitempty"` // 检测规则输出的结构化信息（JSON）
+	Evidence []EventEvidence `gorm:"-" json:"-"`                          // 待保存的证据，由事件存储写入证据表
+}
+
+// 隔离的异常日志行
+type QuarantinedLine struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UploadID  string    `gorm:"type:varchar(32);index" json:"uploadId"` // 上传任务ID
+	CaseID    uint      `gorm:"index" json:"caseId"`
+	FileID    uint      `gorm:"index" json:"fileId"`
+	File      string    `gorm:"type:varchar(255);index" json:"file"`
+	LogType   string    `gorm:"type:varchar(16)" json:"logType"`
+	LineNo    int       `json:"lineNo"`
+	Reason    string    `gorm:"type:varchar(255)" json:"reason"`
+	Content   string    `gorm:"type:text" json:"content"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// 事件指纹的时间分桶：同一规则、源/目标IP在同一时间段内的检测合并为一个事件
+const EventFingerprintBucket = time.Hour
+
+// 事件指纹：检测规则、事件名称与类型、源/目标IP（及目标端口）与开始时间所在分桶相同的事件视为同一事件，
+// 重复检测时合并而非新增；不同规则输出相同事件名称时各自成为独立事件
+func (e *APTEvent) ComputeFingerprint() string {
+	parts := []string{
+		e.Detector, e.EventName, e.EventType,
+		e.SourceIP, e.DestIP,
+		e.StartTime.UTC().Truncate(EventFingerprintBucket).Format(time.RFC3339),
+	}
+	// 按目标服务区分的事件才设置端口，未设置时指纹与不含端口时一致
+	if e.DestPort != 0 {
+		parts = append(parts, strconv.Itoa(e.DestPort))
+	}
+	return rowKey(parts...)
+}
+
+// 证据日志类型
+const (
+	EvidenceAttack = "attack"
+	EvidenceTcp    = "tcp"
+)
+
+// 事件证据：触发事件的原始日志，同一事件的同一日志只记录一次
+type EventEvidence struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	EventID   uint      `gorm:"uniqueIndex:idx_event_evidences_log,priority:1" json:"eventId"`
+	LogType   string    `gorm:"type:varchar(16);uniqueIndex:idx_event_evidences_log,priority:2" json:"logType"`
+	LogID     uint      `gorm:"uniqueIndex:idx_event_evidences_log,priority:3" json:"logId"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// 分析水位：案件上次成功分析时已入库日志的最大ID，增量分析只处理其后入库的数据
+type AnalysisWatermark struct {
+	CaseID      uint      `gorm:"primaryKey;autoIncrement:false" json:"caseId"`
+	AttackLogID uint      `json:"attackLogId"`
+	TcpLogID    uint      `json:"tcpLogId"`
+	UpdatedAt   time.Time `json:"updatedAt"`
 }
 
 // 元数据结构示例（根据检测规则动态生成）
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComputeFingerprint(t *testing.T) {
+	start := time.Date(2024, 3, 1, 8, 10, 0, 0, time.UTC)
+	event := func(modify func(e *APTEvent)) APTEvent {
+		e := APTEvent{
+			Detector: "port_scan", EventName: "PortScan", EventType: "InitialAccess",
+			SourceIP: "10.0.0.1", DestIP: "10.0.0.9", StartTime: start,
+		}
+		modify(&e)
+		return e
+	}
+	base := event(func(*APTEvent) {})
+
+	tests := []struct {
+		name string
+		e    APTEvent
+		same bool
+	}{
+		{"同一时段", event(func(e *APTEvent) { e.StartTime = start.Add(40 * time.Minute) }), true},
+		{"不同时区的同一时刻", event(func(e *APTEvent) { e.StartTime = start.In(time.FixedZone("CST", 8*3600)) }), true},
+		{"描述与严重等级不参与", event(func(e *APTEvent) { e.Description = "x"; e.SeverityLevel = 5 }), true},
+		{"下一时段", event(func(e *APTEvent) { e.StartTime = start.Add(time.Hour) }), false},
+		{"不同检测规则的同名事件", event(func(e *APTEvent) { e.Detector = "wide_port_sweep" }), false},
+		{"不同事件类型", event(func(e *APTEvent) { e.EventType = "LateralMovement" }), false},
+		{"不同目标", event(func(e *APTEvent) { e.DestIP = "10.0.0.8" }), false},
+		{"不同目标端口", event(func(e *APTEvent) { e.DestPort = 445 }), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.e.ComputeFingerprint() == base.ComputeFingerprint(); got != tt.same {
+				t.Errorf("指纹相同 = %v, want %v", got, tt.same)
+			}
+		})
+	}
+}
+
+func TestComputeFingerprintWithoutPort(t *testing.T) {
+	// 未设置目标端口的事件指纹与加入端口前的算法一致，已有事件无需重算
+	e := APTEvent{Detector: "hidden_attack", EventName: "HiddenAttack", EventType: "InitialAccess",
+		SourceIP: "10.0.0.1", DestIP: "10.0.0.9", StartTime: time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)}
+	want := rowKey("hidden_attack", "HiddenAttack", "InitialAccess", "10.0.0.1", "10.0.0.9", "2024-03-01T08:00:00Z")
+	if got := e.ComputeFingerprint(); got != want {
+		t.Errorf("ComputeFingerprint() = %s, want %s", got, want)
+	}
+}
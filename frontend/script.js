@@ -5,6 +5,7 @@ const refreshInterval = 5000; // 5秒刷新间隔
 
 // 全局变量声明
 let currentPage = 1;
+let currentCase = '';
 let files = { attack: [], tcp: [] };
 
 $(document).ready(function() {
@@ -107,6 +108,9 @@ $(document).ready(function() {
 
             files.attack.forEach(file => formData.append("attack", file));
             files.tcp.forEach(file => formData.append("tcp", file));
+            if (currentCase) {
+                formData.append("caseId", currentCase);
+            }
 
             const response = await fetch('/api/v1/upload', {
                 method: 'POST',
@@ -115,11 +119,15 @@ $(document).ready(function() {
 
             if (!response.ok) {
                 const err = await response.json();
-                throw new Error(err.message || '上传失败');
+                throw new Error(err.message || (err.errors || []).join('; ') || '上传失败');
             }
 
             const result = await response.json();
-            handleUploadSuccess(result);
+            const job = await waitForJob(result.data.jobId, $(this));
+            if (job.phase !== 'done') {
+                throw new Error((job.errors || []).join('; ') || jobPhaseLabels[job.phase]);
+            }
+            handleUploadSuccess(job);
 
         } catch (error) {
             console.error('上传错误:', error);
@@ -129,21 +137,50 @@ $(document).ready(function() {
         }
     });
 
+    const jobPhaseLabels = {
+        saving: '保存文件',
+        parsing: '解析日志',
+        detecting: '规则检测',
+        correlating: '时序关联',
+        graphing: '构建攻击图',
+        done: '完成',
+        failed: '失败',
+        cancelled: '已取消'
+    };
+
+    // 轮询上传任务直至结束
+    async function waitForJob(jobId, $btn) {
+        while (true) {
+            const res = await fetch(`/api/v1/jobs/${jobId}`);
+            const data = await res.json();
+            if (data.status !== 'success') {
+                throw new Error(data.message || '任务查询失败');
+            }
+            const job = data.data;
+            if (['done', 'failed', 'cancelled'].includes(job.phase)) {
+                return job;
+            }
+            $btn.text(`${jobPhaseLabels[job.phase] || job.phase}... (${job.linesRead}行)`);
+            await new Promise(resolve => setTimeout(resolve, 1000));
+        }
+    }
+
     // 上传成功处理
-    function handleUploadSuccess(result) {
+    function handleUploadSuccess(job) {
         files = { attack: [], tcp: [] };
         updateFileList('attack');
         updateFileList('tcp');
-        alert(`成功处理 ${result.processed} 个文件`);
+        alert(`成功处理 ${job.files.length} 个文件，共 ${job.linesRead} 行 (耗时 ${job.elapsed})`);
     }
 
     // 日志加载功能
     async function loadLogs() {
         try {
-            const res = await fetch(`/api/v1/refresh?page=${currentPage}&limit=${pageSize}`);
+            const res = await fetch(`/api/v1/refresh?page=${currentPage}&limit=${pageSize}&caseId=${currentCase}`);
             const data = await res.json();
 
             if(data.status === 'success') {
+                loadGraph();
                 renderTable(data.data.events);
                 updatePagination(data.data.total);
                 updateHistory(data.data.events);
@@ -153,6 +190,27 @@ $(document).ready(function() {
         }
     }
 
+    // 加载攻击图（与图存储类型无关）
+    async function loadGraph() {
+        try {
+            const res = await fetch(`/api/v1/graph?caseId=${currentCase}`);
+            const data = await res.json();
+            if (data.status !== 'success') {
+                return;
+            }
+            const edges = data.data.edges || [];
+            if (edges.length === 0) {
+                $('#attackGraph').html('<p>暂无阶段转移</p>');
+                return;
+            }
+            $('#attackGraph').html(edges.map(e =>
+                `<div>${e.kind === 'pivot' ? '[跳板] ' : ''}${e.from} → ${e.to} (置信度 ${e.confidence.toFixed(2)}, ${e.count}次)</div>`
+            ).join(''));
+        } catch (error) {
+            console.error('攻击图加载失败:', error);
+        }
+    }
+
     // 渲染表格
     function renderTable(events) {
         const rows = events.map(event => `
@@ -164,6 +222,7 @@ $(document).ready(function() {
         <td class="severity-${event.severitylevel}">
           ${getSeverityLabel(event.severitylevel)}
         </td>
+        <td>${event.occurrences}</td>
       </tr>
     `).join('');
 
@@ -176,6 +235,7 @@ $(document).ready(function() {
             <th>源IP</th>
             <th>目标IP</th>
             <th>严重等级</th>
+            <th>次数</th>
           </tr>
         </thead>
         <tbody>${rows}</tbody>
@@ -194,7 +254,7 @@ $(document).ready(function() {
             const res = await fetch('/api/v1/inquire', {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
-                body: JSON.stringify({ id: id })
+                body: JSON.stringify({ id: id, caseId: Number(currentCase) || 0 })
             });
 
             const data = await res.json();
@@ -234,9 +294,58 @@ $(document).ready(function() {
         return labels[level - 1] || '未知';
     }
 
+    // 加载案件列表
+    async function loadCases() {
+        try {
+            const res = await fetch('/api/v1/cases');
+            const data = await res.json();
+            if (data.status !== 'success') {
+                return;
+            }
+            const $select = $('#caseSelect');
+            // 案件名称由用户输入，以文本方式写入避免注入
+            $select.empty().append(data.data.map(c => $('<option>').val(c.id).text(c.name)));
+            if (!currentCase && data.data.length > 0) {
+                currentCase = String(data.data[0].id);
+            }
+            $select.val(currentCase);
+        } catch (error) {
+            console.error('案件加载失败:', error);
+        }
+    }
+
+    // 切换案件
+    $('#caseSelect').on('change', function() {
+        currentCase = $(this).val();
+        currentPage = 1;
+        loadLogs();
+    });
+
+    // 新建案件
+    $('#newCaseBtn').on('click', async function() {
+        const name = prompt('请输入案件名称');
+        if (!name) {
+            return;
+        }
+        const res = await fetch('/api/v1/cases', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ name: name })
+        });
+        const data = await res.json();
+        if (data.status !== 'success') {
+            alert('案件创建失败: ' + data.message);
+            return;
+        }
+        currentCase = String(data.data.id);
+        currentPage = 1;
+        await loadCases();
+        loadLogs();
+    });
+
     // 初始化定时任务
     setInterval(loadLogs, refreshInterval);
-    loadLogs();
+    loadCases().then(loadLogs);
 
     // 暴露分页函数到全局
     window.changePage = function(page) {